@@ -3,16 +3,22 @@
 package main
 
 import (
-	"drift/modules/birth"
-	"drift/modules/death"
+	"drift/modules/ancestry"
+	"drift/modules/checkpoint"
+	"drift/modules/events"
 	"drift/modules/initializemodel"
-	"drift/modules/initializepop"
-	"drift/modules/marriage"
+	"drift/modules/paramloader"
+	"drift/modules/rng"
 	"drift/modules/save"
-	"drift/modules/seedpopulation"
+	"drift/modules/stage"
+	"drift/modules/sweep"
+	"drift/types"
 	"flag"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -39,50 +45,141 @@ func main() {
 	mapRootArg := flag.String("map-root",
 		defaultMapRoot,
 		"path to directory containing map files")
+	seedArg := flag.Int64("seed",
+		0,
+		"master random number seed, the same as -set rng_seed=N (0 = use rng_seed, or the clock if that is also 0)")
+	checkpointIntervalArg := flag.Int("checkpoint-interval",
+		0,
+		"save a checkpoint every this many years (0 = no checkpoints)")
+	resumeArg := flag.String("resume",
+		"",
+		"checkpoint file to resume a model from (the model's own parameters are used, -config-root, -map-root and -seed are ignored)")
+	workersArg := flag.Int("workers",
+		1,
+		"number of model runs to execute at the same time")
+	paramsArg := flag.String("params",
+		"",
+		"alternate parameter file layered on top of the defaults in -config-root, may list any subset of the parameters")
+	sweepArg := flag.String("sweep",
+		"",
+		"sweep spec file, runs the model for every combination of parameter values it lists")
+	var setArgs overrides
+	flag.Var(&setArgs, "set", "set a parameter, as name=value (may be repeated, applied after -params)")
 	// Add more parameters as needed
 
 	// Parse the command-line arguments
 	flag.Parse()
-
-	// Initialize the model.
-	// If there is an error, print it to stderr and exit with a non-zero status code.
-	model, err := initializemodel.InitializeModel(*configRootArg, *mapRootArg)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error initializing model: %v\n", err)
+	if *workersArg < 1 {
+		fmt.Fprintf(os.Stderr, "Error: -workers must be at least 1\n")
+		os.Exit(1)
+	}
+	if *workersArg > 1 && (*checkpointIntervalArg > 0 || *resumeArg != "") {
+		fmt.Fprintf(os.Stderr, "Error: -checkpoint-interval and -resume can only be used with -workers 1\n")
+		os.Exit(1)
+	}
+	if *resumeArg != "" && (*paramsArg != "" || len(setArgs) > 0 || *sweepArg != "") {
+		fmt.Fprintf(os.Stderr, "Error: -params, -set and -sweep cannot be used with -resume\n")
+		os.Exit(1)
+	}
+	if *sweepArg != "" && *checkpointIntervalArg > 0 {
+		fmt.Fprintf(os.Stderr, "Error: -checkpoint-interval cannot be used with -sweep\n")
 		os.Exit(1)
 	}
 
-	// Loop over the number of model runs
-	for run := 1; run <= int(model.Parameters["num_runs"]); run++ {
-		print("\nRun ", run, "\n")
-		pop := initializepop.InitializePop(model)
-
-		// Loop over the number years in each model run
-		for year := 0; year <= int(model.Parameters["end_year"]); year++ {
-			if year >= int(model.Parameters["seed_year"]) &&
-				model.FreeParameters["seed"] == -1 {
-				seedpopulation.SeedThePopulation(model, pop, year)
-			}
+	// -seed is checked against the schema like any other parameter
+	if *seedArg != 0 {
+		setArgs = append(setArgs, fmt.Sprintf("rng_seed=%d", *seedArg))
+	}
 
-			birth.Birth(model, pop, year)
-			marriage.Marriage(model, pop, year)
-			death.Death(model, pop, year, run)
-			model.FreeParameters["last_pop_size"] = len(pop.IndData) // save pop size for future growth rate calculations
-			if year%int(model.Parameters["save_interval"]) == 0 {
-				save.Save(model, pop, run, year)
-			}
-			if len(pop.IndData) <= 1 { // Save and quit if population extinct
-				save.Save(model, pop, run, year)
-				break
-			}
+	// Either resume the model from a checkpoint, set up every point of a
+	// sweep, or initialize the model from scratch. If there is an error, print
+	// it to stderr and exit with a non-zero status code.
+	var jobs []job
+	if *resumeArg != "" {
+		base, model, pop, run, year, err := checkpoint.Load(*resumeArg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error resuming model: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Resuming %s from the end of run %d, year %d\n", model.ModelName, run, year)
+		err = save.TruncateResults(model.ModelName, run, year)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error resuming model: %v\n", err)
+			os.Exit(1)
 		}
+		paramloader.PrintParameters(model)
+		jobs = append(jobs, job{model: model, base: base, run: run, pop: pop, startYear: year + 1}) // only the resumed run has a population already
+		jobs = append(jobs, modelJobs(base, run+1)...)
+	} else if *sweepArg != "" {
+		models, err := initializeSweep(*sweepArg, *configRootArg, *mapRootArg, *paramsArg, setArgs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error initializing sweep: %v\n", err)
+			os.Exit(1)
+		}
+		for _, model := range models {
+			jobs = append(jobs, modelJobs(model, 1)...)
+		}
+	} else {
+		model, err := initializemodel.InitializeModel(*configRootArg, *mapRootArg, *paramsArg, setArgs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error initializing model: %v\n", err)
+			os.Exit(1)
+		}
+		save.SaveHeaders(model.ModelName)
+
+		// Choose the master seed that all random number streams are derived from
+		rng.SetMasterSeed(model)
+		paramloader.PrintParameters(model)
+		jobs = modelJobs(model, 1)
+	}
 
-		// Things to do at the end of a model run
-		if model.Parameters["track_DNA"] == 1 {
-			filename := fmt.Sprintf("results/%s genome map.png", model.ModelName)
-			pixelSize := 4
-			save.SaveGenomeMap(pop.Chromosomes, model.ChromosomeArms, filename, pixelSize, int(model.Parameters["NumBits"]))
+	// Pick the lifecycle modules named in the scenario parameter, or else
+	// those of the engine
+	lifecycles := make(map[string]*stage.Lifecycle)
+	for _, j := range jobs {
+		key := lifecycleKey(j.model)
+		if _, ok := lifecycles[key]; ok {
+			continue
+		}
+		lifecycle, err := stage.Load(j.model.Scenario, j.model.StringParameters["engine"])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading scenario: %v\n", err)
+			os.Exit(1)
 		}
+		lifecycles[key] = lifecycle
+	}
+
+	// Hand the runs out to the workers. Each run gets its own copy of the
+	// model so that runs executing at the same time do not share any state.
+	queue := make(chan job)
+	errs := make(chan error, len(jobs))
+	var wg sync.WaitGroup
+	for w := 0; w < *workersArg; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				err := runModel(j.model.Clone(), j.base, lifecycles[lifecycleKey(j.model)], j.run, j.pop, j.startYear, *checkpointIntervalArg)
+				if err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	for _, j := range jobs {
+		queue <- j
+	}
+	close(queue)
+	wg.Wait()
+	close(errs)
+
+	failed := false
+	for err := range errs {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		failed = true
+	}
+	if failed {
+		os.Exit(1)
 	}
 
 	// End of model runs
@@ -90,3 +187,138 @@ func main() {
 	fmt.Printf("Execution time: %s\n", elapsed)
 	fmt.Print("\a")
 }
+
+// job is one model run waiting for a worker.
+type job struct {
+	model     *types.Model
+	base      *types.Model // the model as loaded, to checkpoint alongside the run
+	run       int
+	pop       *types.Pop // nil to start a new population
+	startYear int
+}
+
+// lifecycleKey identifies the lifecycle modules chosen for a model, which
+// depend on its scenario and engine.
+func lifecycleKey(model *types.Model) string {
+	return model.StringParameters["engine"] + "|" + model.Scenario
+}
+
+// modelJobs returns a job for each run of the model from firstRun onwards.
+func modelJobs(model *types.Model, firstRun int) []job {
+	var jobs []job
+	for run := firstRun; run <= int(model.Parameters["num_runs"]); run++ {
+		jobs = append(jobs, job{model: model, base: model, run: run})
+	}
+	return jobs
+}
+
+// initializeSweep sets up a model for every point of a sweep. Each point is
+// the usual model with the swept values applied on top of -params and -set.
+// All points use the same master seed, unless the seed itself is swept, so
+// that differences between points come from the parameters alone.
+func initializeSweep(specFile string, configRoot string, mapRoot string, paramsFile string, sets []string) ([]*types.Model, error) {
+	spec, err := sweep.Load(specFile)
+	if err != nil {
+		return nil, err
+	}
+	sweptSeed := slices.Contains(spec.Names, "rng_seed")
+
+	var models []*types.Model
+	for i := range spec.Points {
+		model, err := initializemodel.InitializeModel(configRoot, mapRoot, paramsFile, append(slices.Clone(sets), spec.Overrides(i)...))
+		if err != nil {
+			return nil, fmt.Errorf("point %d: %v", i+1, err)
+		}
+		if i == 0 {
+			paramloader.PrintParameters(model)
+		}
+		model.Sweep = &types.SweepPoint{Name: model.ModelName + "_sweep", Index: i + 1, Values: spec.Points[i]}
+		model.ModelName = fmt.Sprintf("%s point %d", model.ModelName, i+1)
+		if i > 0 && !sweptSeed {
+			model.Parameters["rng_seed"] = models[0].Parameters["rng_seed"]
+		}
+		rng.SetMasterSeed(model)
+		fmt.Printf("Point %d: %s\n", i+1, strings.Join(spec.Overrides(i), " "))
+		models = append(models, model)
+	}
+
+	name := models[0].Sweep.Name
+	err = save.SaveHeaders(name, append([]string{"point"}, spec.Names...)...)
+	if err != nil {
+		return nil, err
+	}
+	return models, sweep.SaveManifest(fmt.Sprintf("results/%s_manifest.csv", name), spec, models)
+}
+
+// overrides collects the repeated -set flags.
+type overrides []string
+
+func (o *overrides) String() string {
+	return strings.Join(*o, ",")
+}
+
+func (o *overrides) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+// runModel carries out a single model run. If pop is nil a new population is
+// created, otherwise the run carries on with pop from startYear. Base is the
+// model as loaded, before any events, which checkpoints keep so that the runs
+// after a resumed one start from it.
+func runModel(model *types.Model, base *types.Model, lifecycle *stage.Lifecycle, run int, pop *types.Pop, startYear int, checkpointInterval int) error {
+	if model.Sweep != nil {
+		fmt.Printf("\nPoint %d, run %d\n", model.Sweep.Index, run)
+	} else {
+		fmt.Printf("\nRun %d\n", run)
+	}
+	model.FreeParameters["run"] = run
+	if pop == nil {
+		var err error
+		pop, err = lifecycle.InitializePop.Init(model, run)
+		if err != nil {
+			return fmt.Errorf("Error initializing population for run %d: %v", run, err)
+		}
+	}
+	yearlyStages := []stage.Stage{lifecycle.Seed, lifecycle.Birth, lifecycle.Marriage, lifecycle.Death}
+
+	// Loop over the number years in each model run
+	for year := startYear; year <= int(model.Parameters["end_year"]); year++ {
+		events.Apply(model, pop, year)
+		for _, s := range yearlyStages {
+			if err := s.Step(model, pop, year); err != nil {
+				return fmt.Errorf("Error in run %d, year %d: %v", run, year, err)
+			}
+		}
+		model.FreeParameters["last_pop_size"] = len(pop.IndData) // save pop size for future growth rate calculations
+
+		extinct := len(pop.IndData) <= 1
+		if year%int(model.Parameters["save_interval"]) == 0 || extinct { // Save and quit if population extinct
+			if err := lifecycle.Save.Step(model, pop, year); err != nil {
+				return fmt.Errorf("Error saving run %d, year %d: %v", run, year, err)
+			}
+		}
+		if extinct {
+			break
+		}
+
+		if checkpointInterval > 0 && year%checkpointInterval == 0 {
+			err := checkpoint.Save(checkpoint.Filename(model.ModelName), base, model, pop, run, year)
+			if err != nil {
+				return fmt.Errorf("Error saving checkpoint: %v", err)
+			}
+		}
+	}
+
+	// Things to do at the end of a model run
+	if model.Parameters["track_DNA"] == 1 {
+		filename := fmt.Sprintf("results/%s genome map %d.png", model.ModelName, run)
+		pixelSize := 4
+		save.SaveGenomeMap(model, pop.Chromosomes, filename, pixelSize)
+		if ancestry.Enabled(model) {
+			filename = fmt.Sprintf("results/%s ancestry map %d.png", model.ModelName, run)
+			save.SaveAncestryMap(model, pop.Ancestry, filename, pixelSize)
+		}
+	}
+	return nil
+}
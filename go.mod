@@ -4,4 +4,4 @@ go 1.23.0
 
 toolchain go1.23.8
 
-require gonum.org/v1/gonum v0.16.0 // indirect
+require gonum.org/v1/gonum v0.16.0
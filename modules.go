@@ -0,0 +1,14 @@
+package main
+
+// Packages imported here register their lifecycle modules with the stage
+// registry when the program starts. To make a new module available to the
+// scenario parameter, add a blank import for its package to this list.
+import (
+	_ "drift/modules/birth"
+	_ "drift/modules/death"
+	_ "drift/modules/generations"
+	_ "drift/modules/initializepop"
+	_ "drift/modules/marriage"
+	_ "drift/modules/save"
+	_ "drift/modules/seedpopulation"
+)
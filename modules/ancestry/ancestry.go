@@ -0,0 +1,123 @@
+package ancestry
+
+import (
+	"drift/modules/genome"
+	"drift/types"
+	"slices"
+)
+
+// Enabled reports whether ancestry labels are tracked.
+func Enabled(model *types.Model) bool {
+	return model.StringParameters["ancestry"] != "off"
+}
+
+// LabelFounders gives every individual of the starting population an ancestry
+// label: their own, numbered from 1, or with founder_groups set, that of
+// their group, the founders being dealt into the groups in order of ID.
+func LabelFounders(model *types.Model, pop *types.Pop) {
+	groups := int(model.Parameters["founder_groups"])
+	numLabels := len(pop.IndData)
+	if groups > 0 {
+		numLabels = min(groups, numLabels)
+	}
+	model.FreeParameters["num_labels"] = numLabels
+	for i, ind := range pop.IDs() {
+		Label(model, pop, ind, i%numLabels+1)
+	}
+}
+
+// Label marks every bit an individual carries as coming from the given
+// founder label, and records them as descended from it.
+func Label(model *types.Model, pop *types.Pop, ind int, label int) {
+	numbits := model.FreeParameters["numbits"]
+	carried := model.SexChromosomes.Carried[pop.Ind(ind).Sex]
+	labels := make([]types.Segments, 2)
+	for strand := 0; strand <= 1; strand++ {
+		carried[strand].Runs(0, numbits, func(start int, length int) {
+			labels[strand] = append(labels[strand], types.Segment{Start: start, End: start + length, Label: uint16(label)})
+		})
+	}
+	pop.Ancestry[ind] = labels
+
+	pop.Descent[ind] = []uint16{uint16(label)}
+}
+
+// Inherit works out a child's labels from the gametes made with the two
+// masks, as meiosis does for the seed bits. It returns nil if the child has
+// no labelled bits. It only reads the population, so it can be called for
+// many children at once.
+func Inherit(model *types.Model, pop *types.Pop, masks [2]genome.Haplotype, carried [2]genome.Haplotype, dad int, mom int) []types.Segments {
+	var child []types.Segments
+	for strand, parent := range [2]int{dad, mom} {
+		labels, ok := pop.Ancestry[parent]
+		if !ok {
+			continue
+		}
+		// as in meiosis, a set mask bit means copy 0
+		fromCopy0 := genome.And(masks[strand], carried[strand])
+		fromCopy1 := genome.Xor(carried[strand], fromCopy0)
+		gamete := merge(slices.Concat(cut(labels[0], fromCopy0), cut(labels[1], fromCopy1)))
+		if len(gamete) > 0 {
+			if child == nil {
+				child = make([]types.Segments, 2)
+			}
+			child[strand] = gamete
+		}
+	}
+	return child
+}
+
+// cut returns the parts of the segments that lie on the set bits of keep.
+func cut(segments types.Segments, keep genome.Haplotype) types.Segments {
+	var kept types.Segments
+	for _, segment := range segments {
+		keep.Runs(segment.Start, segment.End, func(start int, length int) {
+			kept = append(kept, types.Segment{Start: start, End: start + length, Label: segment.Label})
+		})
+	}
+	return kept
+}
+
+// merge puts segments that do not overlap in order, and joins those that
+// touch and share a label.
+func merge(segments types.Segments) types.Segments {
+	slices.SortFunc(segments, func(a, b types.Segment) int { return a.Start - b.Start })
+	var merged types.Segments
+	for _, segment := range segments {
+		if n := len(merged); n > 0 && merged[n-1].End == segment.Start && merged[n-1].Label == segment.Label {
+			merged[n-1].End = segment.End
+			continue
+		}
+		merged = append(merged, segment)
+	}
+	return merged
+}
+
+// InheritDescent returns the labels a child is genealogically descended from,
+// those of either parent in increasing order, or nil if there are none. A
+// child of only one descended parent shares that parent's slice, so descent
+// slices must not be changed once stored.
+func InheritDescent(pop *types.Pop, dad int, mom int) []uint16 {
+	dadDescent, momDescent := pop.Descent[dad], pop.Descent[mom]
+	switch {
+	case dadDescent == nil:
+		return momDescent
+	case momDescent == nil:
+		return dadDescent
+	}
+	descent := make([]uint16, 0, len(dadDescent)+len(momDescent))
+	for len(dadDescent) > 0 && len(momDescent) > 0 {
+		switch {
+		case dadDescent[0] < momDescent[0]:
+			descent = append(descent, dadDescent[0])
+			dadDescent = dadDescent[1:]
+		case momDescent[0] < dadDescent[0]:
+			descent = append(descent, momDescent[0])
+			momDescent = momDescent[1:]
+		default:
+			descent = append(descent, dadDescent[0])
+			dadDescent, momDescent = dadDescent[1:], momDescent[1:]
+		}
+	}
+	return slices.Concat(descent, dadDescent, momDescent)
+}
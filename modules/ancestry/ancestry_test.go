@@ -0,0 +1,166 @@
+package ancestry
+
+import (
+	"drift/modules/genome"
+	"drift/types"
+	"slices"
+	"testing"
+)
+
+// haplotype builds a haplotype of numbits with the listed bits set.
+func haplotype(numbits int, set ...int) genome.Haplotype {
+	h := genome.New(numbits)
+	for _, bit := range set {
+		h.Set(bit)
+	}
+	return h
+}
+
+func TestInherit(t *testing.T) {
+	const numbits = 70 // more than one word
+	all := genome.RangeMask(numbits, 0, numbits)
+	dad := []types.Segments{
+		{{Start: 0, End: 40, Label: 1}, {Start: 40, End: 70, Label: 2}},
+		{{Start: 0, End: 70, Label: 3}},
+	}
+	mom := []types.Segments{
+		{{Start: 10, End: 20, Label: 4}},
+		nil,
+	}
+	tests := []struct {
+		name    string
+		masks   [2]genome.Haplotype
+		carried [2]genome.Haplotype
+		want    []types.Segments
+	}{
+		{
+			"copy 0 from both",
+			[2]genome.Haplotype{all, all},
+			[2]genome.Haplotype{all, all},
+			[]types.Segments{dad[0], mom[0]},
+		},
+		{
+			"copy 1 from both",
+			[2]genome.Haplotype{genome.New(numbits), genome.New(numbits)},
+			[2]genome.Haplotype{all, all},
+			[]types.Segments{dad[1], nil},
+		},
+		{
+			"crossover inside a segment",
+			[2]genome.Haplotype{genome.RangeMask(numbits, 0, 30), genome.RangeMask(numbits, 15, numbits)},
+			[2]genome.Haplotype{all, all},
+			[]types.Segments{
+				{{Start: 0, End: 30, Label: 1}, {Start: 30, End: 70, Label: 3}},
+				{{Start: 15, End: 20, Label: 4}},
+			},
+		},
+		{
+			"crossovers that join touching segments with the same label",
+			[2]genome.Haplotype{haplotype(numbits, 5), all},
+			[2]genome.Haplotype{all, all},
+			[]types.Segments{
+				{{Start: 0, End: 5, Label: 3}, {Start: 5, End: 6, Label: 1}, {Start: 6, End: 70, Label: 3}},
+				mom[0],
+			},
+		},
+		{
+			"bits the child does not carry",
+			[2]genome.Haplotype{all, all},
+			[2]genome.Haplotype{genome.RangeMask(numbits, 35, 45), genome.RangeMask(numbits, 0, 10)},
+			[]types.Segments{{{Start: 35, End: 40, Label: 1}, {Start: 40, End: 45, Label: 2}}, nil},
+		},
+		{
+			"nothing labelled",
+			[2]genome.Haplotype{genome.New(numbits), genome.New(numbits)},
+			[2]genome.Haplotype{genome.RangeMask(numbits, 0, 0), all},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := types.NewModel()
+			model.FreeParameters["numbits"] = numbits
+			pop := types.NewPop()
+			pop.Ancestry[1] = dad
+			pop.Ancestry[2] = mom
+			got := Inherit(model, pop, tt.masks, tt.carried, 1, 2)
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Fatalf("Inherit() = %v, want %v", got, tt.want)
+			}
+			// bit by bit, the child has the label of the copy the mask picks
+			for strand, parent := range [][]types.Segments{dad, mom} {
+				for bit := 0; bit < numbits; bit++ {
+					want := uint16(0)
+					if tt.carried[strand].Get(bit) {
+						from := 1
+						if tt.masks[strand].Get(bit) {
+							from = 0
+						}
+						want = parent[from].At(bit)
+					}
+					var label uint16
+					if got != nil {
+						label = got[strand].At(bit)
+					}
+					if label != want {
+						t.Fatalf("strand %d bit %d has label %d, want %d", strand, bit, label, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLabel(t *testing.T) {
+	const numbits = 100
+	model := types.NewModel()
+	model.FreeParameters["numbits"] = numbits
+	model.FreeParameters["num_labels"] = 3
+	carried := genome.RangeMask(numbits, 0, numbits)
+	carried.ClearRange(60, 80)
+	model.SexChromosomes.Carried[0] = [2]genome.Haplotype{carried, genome.RangeMask(numbits, 0, numbits)}
+	pop := types.NewPop()
+	pop.Add(types.Individual{ID: 1, Sex: 0})
+
+	Label(model, pop, 1, 2)
+	want := []types.Segments{
+		{{Start: 0, End: 60, Label: 2}, {Start: 80, End: 100, Label: 2}},
+		{{Start: 0, End: 100, Label: 2}},
+	}
+	if got := pop.Ancestry[1]; !slices.EqualFunc(got, want, slices.Equal) {
+		t.Fatalf("Label() gave %v, want %v", got, want)
+	}
+	if !slices.Equal(pop.Descent[1], []uint16{2}) {
+		t.Fatalf("Label() gave descent %v, want label 2 only", pop.Descent[1])
+	}
+}
+
+func TestInheritDescent(t *testing.T) {
+	const dad, mom = 1, 2
+	tests := []struct {
+		name string
+		dad  []uint16
+		mom  []uint16
+		want []uint16
+	}{
+		{"neither parent", nil, nil, nil},
+		{"father only", []uint16{3, 7}, nil, []uint16{3, 7}},
+		{"mother only", nil, []uint16{5}, []uint16{5}},
+		{"interleaved", []uint16{1, 4, 9}, []uint16{2, 4, 10, 300}, []uint16{1, 2, 4, 9, 10, 300}},
+		{"the same labels", []uint16{2, 3}, []uint16{2, 3}, []uint16{2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pop := types.NewPop()
+			if tt.dad != nil {
+				pop.Descent[dad] = tt.dad
+			}
+			if tt.mom != nil {
+				pop.Descent[mom] = tt.mom
+			}
+			if got := InheritDescent(pop, dad, mom); !slices.Equal(got, tt.want) {
+				t.Fatalf("InheritDescent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,229 +1,532 @@
 package birth
 
 import (
+	"drift/modules/ancestry"
+	"drift/modules/coords"
+	"drift/modules/crossover"
+	"drift/modules/genome"
 	"drift/modules/mutation"
+	"drift/modules/rng"
+	"drift/modules/selection"
+	"drift/modules/stage"
 	"drift/types"
-	"fmt"
-	"math/rand"
-	"strings"
+	"math/bits"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"gonum.org/v1/gonum/stat/distuv"
 )
 
-func Birth(model *types.Model, pop *types.Pop, year int) {
+func init() {
+	stage.Register(stage.Birth, stage.Default, stage.StageFunc(
+		func(model *types.Model, pop *types.Pop, year int) error {
+			return Birth(model, pop, year)
+		}))
+}
+
+// conception holds a birth decided on in the first phase of Birth, and the
+// genome built for the child in the second.
+type conception struct {
+	dad, mom, child int
+
+	chromosomes    []genome.Haplotype // nil if the child inherited no seed bits
+	alleleCount    int
+	numBlocks      int
+	centromeres    []uint64 // nil if the child inherited no seed centromeres
+	numCentromeres int
+	ancestry       []types.Segments // nil if the child inherited no labelled bits
+	inherited      [2][]int         // IDs of the mutations inherited from dad and mom
+	newMutations   []mutation.Draft
+	err            error // set if the genome could not be built
+}
+
+// Birth works in three phases. First, the women who give birth this year are
+// chosen and their children added to the population, one at a time. Second,
+// if DNA or mutations are tracked, the children's genomes are built in
+// parallel. Each child draws from its own random number stream, so the
+// results do not depend on how the work is spread across cores. Third, the
+// genomes are recorded in the population, one child at a time.
+func Birth(model *types.Model, pop *types.Pop, year int) error {
+	rnd := model.RNG[rng.Birth]
+	var births []*conception
+
+	// Hermaphrodites need no spouse, any adult can bear seed
+	hermaphrodite := Hermaphrodite(model)
+	var adults []int
+	if hermaphrodite {
+		for i := range pop.IndData {
+			if year-pop.IndData[i].BirthYear >= int(model.Parameters["maturity"]) {
+				adults = append(adults, pop.IndData[i].ID)
+			}
+		}
+	}
 
 	// First, find eligible females and roll the dice
-	for ind := range pop.IndData {
+	for _, ind := range pop.IDs() {
+		woman := pop.Ind(ind)
 		// skip males
-		if pop.IndData[ind]["sex"] == 0 {
+		if woman.Sex == 0 && !hermaphrodite {
 			continue
 		}
 		// skip unmarried women
-		if pop.IndData[ind]["marriage_state"] == -1 {
+		if woman.MarriageState == -1 && !hermaphrodite {
 			continue
 		}
-		age := year - pop.IndData[ind]["birth_year"]
+		age := year - woman.BirthYear
 		// skip adolescent girls
 		if age < int(model.Parameters["maturity"]) {
 			continue
 		}
 		// skip women in menopause
-		if float64(age) > float64(pop.IndData[ind]["lifespan"])*model.Parameters["menopause"] {
+		if float64(age) > float64(woman.Lifespan)*model.ParamAt("menopause", year) {
 			continue
 		}
 		// skip women with young children
-		if int(pop.IndData[ind]["last_birth_year"])+int(model.Parameters["spacing"]) >= year {
+		if woman.LastBirthYear+int(model.Parameters["spacing"]) >= year {
 			continue
 		}
 		// Failed to get pregnant this year
-		if rand.Intn(int(model.Parameters["birth_prob"])) != 0 {
+		if rnd.IntN(int(model.ParamAt("birth_prob", year))) != 0 {
 			continue
 		}
 
 		// Next, put 'em in the oven
 		mom := ind
-		dad := pop.IndData[ind]["marriage_state"]
-		// TO DO: fitness ALSO affects survivorship each year, work out a way to
-		// use fitness for birth OR survivorship OR both
-
-		// the average of the maternal and paternal fitness affects birth probability
-		fitness := 1.0
-		if model.Parameters["track_mutations"] == 1 {
-			pfit := float64(pop.IndData[dad]["fitness"])
-			mfit := float64(pop.IndData[mom]["fitness"])
-			fitness = (pfit + mfit) / 2
-			fitness = fitness / model.Parameters["mu_Scale_factor"]
-		}
-		chance := rand.Float64()
+		dad := woman.MarriageState
+		if hermaphrodite {
+			dad = Partner(model, rnd.Rand, mom, adults)
+		}
+		// with birth selection, the average of the maternal and paternal
+		// fitness affects birth probability
+		fitness := selection.For(model).BirthChance(model, pop.Ind(dad), woman)
+		chance := rnd.Float64()
 		if chance < fitness {
 			model.FreeParameters["indID"] += 1
 			child := model.FreeParameters["indID"]
-			createChild(model, pop, dad, mom, child, year)
-
-			// If DNA or mutations are being tracked, bitmasks will be created that
-			// will be used to control meiosis and mutation inheritance. These will
-			// be used for both meiosis and mutation inheritance, so we will set
-			// them up once and use them at will.
-
-			if model.Parameters["track_DNA"] > 0 || model.Parameters["track_mutations"] > 0 {
-				var genomemask1, genomemask2 []uint64
-				var centsmask1, centsmask2 uint64
-				genomemask1, centsmask1 = createMask(model, 0)
-				genomemask2, centsmask2 = createMask(model, 1)
-
-				// Add tracked DNA
-				if model.Parameters["track_DNA"] > 0 {
-					// only create a child's chromosomes if there is something to track at least one parent
-					if pop.IndData[dad]["allele_count"] > 0 || pop.IndData[mom]["allele_count"] > 0 {
-						pop.Chromosomes[child] = [][]uint64{make([]uint64, (model.FreeParameters["NumBits"]+63)/64), make([]uint64, (model.FreeParameters["NumBits"]+63)/64)}
-					}
-					numSetBits := 0
-					// only go through meiosis if there is a set bit in mom or dad
-					if pop.IndData[dad]["allele_count"] > 0 {
-						meiosis(pop, genomemask1, dad, child, 0)
-						numSetBits += countSetBits(pop.Chromosomes[child][0])
-					}
-					if pop.IndData[mom]["allele_count"] > 0 {
-						meiosis(pop, genomemask2, mom, child, 1)
-						numSetBits += countSetBits(pop.Chromosomes[child][1])
-					}
-					pop.IndData[child]["allele_count"] = numSetBits
-					// delete the child's chromosomes if they inherited zero set bits
-					if pop.IndData[child]["allele_count"] < 1 {
-						delete(pop.Chromosomes, child)
-					} else {
-						pop.IndData[child]["num_blocks"] = countContiguousBlocks(model, pop, child, 0)
-						pop.IndData[child]["num_blocks"] += countContiguousBlocks(model, pop, child, 1)
-					}
-
-					// inherit centromeres if mom or dad have a set bit in their centromeres
-					if pop.IndData[dad]["centromere_count"] > 0 || pop.IndData[mom]["centromere_count"] > 0 {
-						inheritCentromeres(model, pop, centsmask1, centsmask2, dad, mom, child)
-					}
-
-					// track avenues of descent from the seed individual(s)
-					pop.IndData[child]["Y_gens"] = -1
-					if pop.IndData[dad]["Y_gens"] > -1 && pop.IndData[child]["sex"] == 0 {
-						pop.IndData[child]["Y_gens"] = pop.IndData[dad]["Y_gens"] + 1
-					}
-					pop.IndData[child]["mt_gens"] = -1
-					if pop.IndData[mom]["mt_gens"] > -1 {
-						pop.IndData[child]["mt_gens"] = pop.IndData[mom]["mt_gens"] + 1
-					}
-
-					pop.IndData[child]["min_genealo_gens"] = -1
-					minGenealo := pop.IndData[dad]["min_genealo_gens"]
-					if pop.IndData[mom]["min_genealo_gens"] > minGenealo {
-						minGenealo = pop.IndData[mom]["min_genealo_gens"]
-					}
-					if minGenealo > -1 {
-						pop.IndData[child]["min_genealo_gens"] = minGenealo + 1
-					}
-
-					pop.IndData[child]["max_genealo_gens"] = -1
-					maxGenealo := pop.IndData[dad]["max_genealo_gens"]
-					if pop.IndData[mom]["max_genealo_gens"] > maxGenealo {
-						maxGenealo = pop.IndData[mom]["max_genealo_gens"]
-					}
-					if maxGenealo > -1 {
-						pop.IndData[child]["max_genealo_gens"] = maxGenealo + 1
-					}
-				}
-
-				// Assign mutations, both inherited and de novo
-				if model.Parameters["track_mutations"] > 0 {
-					mutation.InheritMutations(pop, genomemask1, dad, child, 0)
-					mutation.InheritMutations(pop, genomemask2, mom, child, 1)
-					mutation.GenerateNewMutations(model, pop, child)
-					numMutations, mutationLoad := mutation.CountFitnessAndMutations(pop, child)
-					fitness := 1 + mutationLoad
-					pop.IndData[child]["fitness"] = int(float64(fitness) * model.Parameters["mu_scale_factor"])
-					pop.IndData[child]["num_mutations"] = numMutations
-				}
-			}
+			createChild(model, pop, rnd.Rand, dad, mom, child, year)
+			births = append(births, &conception{dad: dad, mom: mom, child: child})
 			pop.Tracking["births"]++
 		}
 	}
+
+	return addGenomes(model, pop, births, year)
 }
 
-func createChild(model *types.Model, pop *types.Pop, dad, mom, child int, year int) {
+// Offspring adds a child to the population for each couple listed, father
+// first, and builds their genomes. It is for engines that decide for
+// themselves who has children, such as discrete generations.
+func Offspring(model *types.Model, pop *types.Pop, couples [][2]int, year int) error {
+	rnd := model.RNG[rng.Birth]
+	births := make([]*conception, 0, len(couples))
+	for _, couple := range couples {
+		model.FreeParameters["indID"] += 1
+		child := model.FreeParameters["indID"]
+		createChild(model, pop, rnd.Rand, couple[0], couple[1], child, year)
+		births = append(births, &conception{dad: couple[0], mom: couple[1], child: child})
+		pop.Tracking["births"]++
+	}
+	return addGenomes(model, pop, births, year)
+}
 
-	// potential lifespan is the average of the parents X the lifespan drop per generation, but it bottoms out at min_lifespan
-	lifespan := int((pop.IndData[dad]["lifespan"] + pop.IndData[mom]["lifespan"]) / 2 * int(model.Parameters["lifespan_drop"]))
-	if lifespan < int(model.Parameters["min_lifespan"]) {
-		lifespan = int(model.Parameters["min_lifespan"])
+// Hermaphrodite reports whether every individual can act as either parent.
+func Hermaphrodite(model *types.Model) bool {
+	return model.StringParameters["mating_system"] == "hermaphrodite"
+}
+
+// Partner chooses the pollen parent for a hermaphrodite's seed. With
+// probability selfing_rate, or if there is no one else, the plant fertilizes
+// itself. Otherwise the partner is drawn at random from the other adults.
+func Partner(model *types.Model, rnd *rand.Rand, ind int, adults []int) int {
+	if rnd.Float64() < model.Parameters["selfing_rate"] || len(adults) < 2 {
+		return ind
+	}
+	for {
+		partner := adults[rnd.IntN(len(adults))]
+		if partner != ind {
+			return partner
+		}
+	}
+}
+
+// addGenomes builds the genomes of the children in parallel, if DNA or
+// mutations are tracked, and then records them one child at a time.
+func addGenomes(model *types.Model, pop *types.Pop, births []*conception, year int) error {
+	if model.Parameters["track_DNA"] > 0 || model.Parameters["track_mutations"] > 0 {
+		parallelFor(len(births), func(i int) {
+			buildGenome(model, pop, births[i], year)
+		})
+		for _, c := range births {
+			if c.err != nil {
+				return c.err
+			}
+		}
+		for _, c := range births {
+			recordGenome(model, pop, c)
+		}
+	}
+	return nil
+}
+
+// buildGenome runs meiosis and draws new mutations for a child. It only reads
+// the population, so it can be called for many children at once.
+func buildGenome(model *types.Model, pop *types.Pop, c *conception, year int) {
+	rnd := rng.NewIndividualStream(model, rng.Genome, c.child)
+	father, mother := pop.Ind(c.dad), pop.Ind(c.mom)
+
+	// Bitmasks are created that will be used to control meiosis and mutation
+	// inheritance. These will be used for both meiosis and mutation
+	// inheritance, so we will set them up once and use them at will.
+	// A selfed child gets two independent gametes from the same parent.
+	childSex := pop.Ind(c.child).Sex
+	carried := model.SexChromosomes.Carried[childSex]
+	genomemask1, centsmask1, err := createMask(model, rnd.Rand, father.Sex, childSex)
+	if err != nil {
+		c.err = err
+		return
+	}
+	genomemask2, centsmask2, err := createMask(model, rnd.Rand, mother.Sex, childSex)
+	if err != nil {
+		c.err = err
+		return
+	}
+
+	// Add tracked DNA
+	if model.Parameters["track_DNA"] > 0 {
+		// only create a child's chromosomes if there is something to track at least one parent
+		if father.AlleleCount > 0 || mother.AlleleCount > 0 {
+			c.chromosomes = []genome.Haplotype{genome.New(model.FreeParameters["numbits"]), genome.New(model.FreeParameters["numbits"])}
+		}
+		// only go through meiosis if there is a set bit in mom or dad
+		if father.AlleleCount > 0 {
+			c.chromosomes[0] = meiosis(pop, genomemask1, carried[0], c.dad)
+			c.alleleCount += c.chromosomes[0].Count()
+		}
+		if mother.AlleleCount > 0 {
+			c.chromosomes[1] = meiosis(pop, genomemask2, carried[1], c.mom)
+			c.alleleCount += c.chromosomes[1].Count()
+		}
+		// drop the child's chromosomes if they inherited zero set bits
+		if c.alleleCount < 1 {
+			c.chromosomes = nil
+		} else {
+			c.numBlocks = countContiguousBlocks(model, c.chromosomes[0])
+			c.numBlocks += countContiguousBlocks(model, c.chromosomes[1])
+		}
+
+		// inherit centromeres if mom or dad have a set bit in their centromeres
+		if father.NumCentromeres > 0 || mother.NumCentromeres > 0 {
+			c.centromeres, c.numCentromeres = inheritCentromeres(model, pop, centsmask1, centsmask2, childSex, c.dad, c.mom)
+		}
+
+		if ancestry.Enabled(model) {
+			c.ancestry = ancestry.Inherit(model, pop, [2]genome.Haplotype{genomemask1, genomemask2}, carried, c.dad, c.mom)
+		}
+	}
+
+	// Work out the mutations, both inherited and de novo
+	if model.Parameters["track_mutations"] > 0 {
+		c.inherited[0] = mutation.InheritMutations(model, pop, genomemask1, carried[0], c.dad)
+		c.inherited[1] = mutation.InheritMutations(model, pop, genomemask2, carried[1], c.mom)
+		// a new mutation on a missing sex chromosome is lost
+		for _, draft := range mutation.DrawNewMutations(model, rnd, year) {
+			if carried[draft.Strand].Get(coords.Bin(model, draft.Position)) {
+				c.newMutations = append(c.newMutations, draft)
+			}
+		}
+	}
+}
+
+// recordGenome stores the genome built by buildGenome in the population.
+func recordGenome(model *types.Model, pop *types.Pop, c *conception) {
+	father, mother, kid := pop.Ind(c.dad), pop.Ind(c.mom), pop.Ind(c.child)
+
+	if model.Parameters["track_DNA"] > 0 {
+		if c.chromosomes != nil {
+			pop.Chromosomes[c.child] = c.chromosomes
+		}
+		kid.AlleleCount = c.alleleCount
+		kid.NumBlocks = c.numBlocks
+		if c.centromeres != nil {
+			pop.Centromeres[c.child] = c.centromeres
+		}
+		kid.NumCentromeres = c.numCentromeres
+		if c.ancestry != nil {
+			pop.Ancestry[c.child] = c.ancestry
+		}
+		if descent := ancestry.InheritDescent(pop, c.dad, c.mom); descent != nil {
+			pop.Descent[c.child] = descent
+		}
+
+		// track avenues of descent from the seed individual(s)
+		kid.YGens = -1
+		if father.YGens > -1 && kid.Sex == 0 {
+			kid.YGens = father.YGens + 1
+		}
+		kid.MtGens = -1
+		if mother.MtGens > -1 {
+			kid.MtGens = mother.MtGens + 1
+		}
+
+		kid.MinGenealoGens = -1
+		minGenealo := father.MinGenealoGens
+		if mother.MinGenealoGens > minGenealo {
+			minGenealo = mother.MinGenealoGens
+		}
+		if minGenealo > -1 {
+			kid.MinGenealoGens = minGenealo + 1
+		}
+
+		kid.SeedDescent = father.SeedDescent | mother.SeedDescent
+
+		kid.MaxGenealoGens = -1
+		maxGenealo := father.MaxGenealoGens
+		if mother.MaxGenealoGens > maxGenealo {
+			maxGenealo = mother.MaxGenealoGens
+		}
+		if maxGenealo > -1 {
+			kid.MaxGenealoGens = maxGenealo + 1
+		}
+	}
+
+	// Assign mutations, both inherited and de novo
+	if model.Parameters["track_mutations"] > 0 {
+		mutation.AddMutations(model, pop, c.child, c.inherited, c.newMutations)
+		numMutations, fitness := mutation.CountFitnessAndMutations(model, pop, c.child)
+		kid.Fitness = int(float64(fitness) * model.Parameters["mu_scale_factor"])
+		kid.NumMutations = numMutations
 	}
+}
 
-	pop.IndData[child] = map[string]int{
-		"dad":            dad,
-		"mom":            mom,
-		"sex":            rand.Intn(2),
-		"birth_year":     year,
-		"lifespan":       lifespan,
-		"marriage_state": -1,
-		"lat":            0,
-		"lon":            0,
+// parallelFor calls f(0) to f(n-1), spread across the available CPU cores.
+func parallelFor(n int, f func(i int)) {
+	workers := min(runtime.GOMAXPROCS(0), n)
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+		return
 	}
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1)) - 1; i < n; i = int(next.Add(1)) - 1 {
+				f(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
 
-	pop.IndData[mom]["last_birth_year"] = year
-	if _, exists := pop.IndData[mom]["numbirths"]; !exists {
-		pop.IndData[mom]["numbirths"] = 0
+func createChild(model *types.Model, pop *types.Pop, rnd *rand.Rand, dad, mom, child int, year int) {
+	father, mother := pop.Ind(dad), pop.Ind(mom)
+
+	// potential lifespan is the average of the parents X the lifespan drop per generation, but it bottoms out at min_lifespan
+	lifespan := int(float64(father.Lifespan+mother.Lifespan) / 2 * model.ParamAt("lifespan_drop", year))
+	if lifespan < int(model.Parameters["min_lifespan"]) {
+		lifespan = int(model.Parameters["min_lifespan"])
 	}
-	pop.IndData[mom]["numbirths"]++
+
+	mother.LastBirthYear = year
+	mother.NumBirths++
+
+	pop.Add(types.Individual{
+		ID:             child,
+		Dad:            dad,
+		Mom:            mom,
+		Sex:            rnd.IntN(2),
+		BirthYear:      year,
+		Lifespan:       lifespan,
+		MarriageState:  -1,
+		Fitness:        int(model.Parameters["mu_scale_factor"]),
+		YGens:          -1,
+		MtGens:         -1,
+		MinGenealoGens: -1,
+		MaxGenealoGens: -1,
+		Lat:            father.Lat,
+		Lon:            father.Lon,
+	})
 }
 
-func createMask(model *types.Model, sex int) ([]uint64, uint64) {
+// createMask draws the crossovers for one gamete from a parent to a child of
+// the given sexes. The X (or Z) crosses over like an autosome in the
+// homogametic sex. The heterogametic parent passes on the Y (or W, or for X0
+// nothing) to a child of the same sex and the X otherwise, with a single
+// crossover inside each pseudo-autosomal region.
+func createMask(model *types.Model, rnd *rand.Rand, parentSex int, childSex int) (genome.Haplotype, uint64, error) {
 
-	// masks are uint64 (8-byte unsigned integers with 64 bits of memory). It takes about 50 uint64 to code for one copy of a 3,100 bit genome
+	// masks are haplotypes, stored as uint64s (8-byte unsigned integers with 64 bits of memory). It takes about 50 uint64 to code for one copy of a 3,100 bit genome
 	// the centromere mask is a single uint64, therefore models with up to 64 chromosomes can be handled
 
-	numUint64s := (model.FreeParameters["numbits"] + 63) / 64
-	genomemask := make([]uint64, numUint64s)
+	genomemask := genome.New(model.FreeParameters["numbits"])
 	var centromask uint64
 
-	for chrom := 1; chrom < len(model.ChromosomeArms); chrom++ {
+	sc := &model.SexChromosomes
+	het := sc.Heterogametic()
+	for chrom := 1; chrom <= len(model.ChromosomeArms); chrom++ {
+		// the Y never crosses over, nor does the X in the heterogametic sex
+		if het >= 0 && (chrom == sc.Y || (chrom == sc.X && parentSex == het)) {
+			continue
+		}
 		// in biology, chromosomes generally have a shorter 'p' arm and a longer 'q' arm', the lengths were loaded previously
 		// chromosomeArms[chrom][0] = p, chromosomeArms[chrom][1] = q
 		// chromosomeArms[chrom][0][0] = start of p arm in bits, chromosomeArms[chrom][0][1] = length of p arm in bits
-		pstart := model.ChromosomeArms[chrom][0][0]
-		qstart := model.ChromosomeArms[chrom][1][0]
-		plen := model.ChromosomeArms[chrom][0][1]
-		qlen := model.ChromosomeArms[chrom][1][1]
+		pstart, plen := coords.Arm(model, chrom, 0)
+		qstart, qlen := coords.Arm(model, chrom, 1)
 
 		if plen <= 0 || qlen <= 0 {
 			continue
 		}
 
+		// crossovers drawn from the genetic length of the whole chromosome
+		if model.StringParameters["crossover_model"] != "arm" {
+			breakpoints, err := crossover.Draw(model, rnd, parentSex, chrom)
+			if err != nil {
+				return nil, 0, err
+			}
+			if applyBreakpoints(model, genomemask, chrom, rnd.IntN(2), breakpoints) == 0 {
+				centromask |= (1 << (chrom % 64))
+			}
+			geneConversion(model, rnd, genomemask, chrom)
+			continue
+		}
+
 		// choose a random place on each chromosome arm and decide if the paternal
 		// or maternal centromere will be inherited by the child
-		ploc := rand.Intn(plen)
-		qloc := rand.Intn(qlen)
-		whichCopy := rand.Intn(2)
+		ploc := armLocation(model, rnd, parentSex, chrom, 0)
+		qloc := armLocation(model, rnd, parentSex, chrom, 1)
+		whichCopy := rnd.IntN(2)
 
 		if whichCopy == 1 {
 			// example: 00001111x11110000, where 0 = paternal, 1 = maternal, and x = the centromere
-			for i := pstart + ploc; i < qstart+qloc; i++ {
-				genomemask[i/64] |= (1 << (i % 64))
-			}
+			genomemask.SetRange(pstart+ploc, qstart+qloc)
 			centromask |= (1 << (chrom % 64))
 		} else {
 			// example: 11110000x00001111
-			for i := pstart; i < pstart+ploc; i++ {
-				genomemask[i/64] |= (1 << (i % 64))
+			genomemask.SetRange(pstart, pstart+ploc)
+			genomemask.SetRange(qstart+qloc, qstart+qlen)
+		}
+		geneConversion(model, rnd, genomemask, chrom)
+	}
+
+	if het >= 0 && parentSex == het {
+		// the Y is on the strand from the parent's parent of the same sex, and
+		// the X on the other
+		chrom, strand := sc.X, 1-het
+		if childSex == het {
+			chrom, strand = sc.Y, het
+		}
+		if chrom > 0 && strand == 0 {
+			for arm := 0; arm <= 1; arm++ {
+				start, length := coords.Arm(model, chrom, arm)
+				genomemask.SetRange(start, start+length)
+			}
+			centromask |= (1 << (chrom % 64))
+		}
+
+		// the pseudo-autosomal region next to the centromere goes with the
+		// sex-specific part, and the tip beyond the crossover with the other
+		// strand
+		for _, par := range sc.PAR {
+			loc := par[0] + rnd.IntN(par[1]-par[0]+1)
+			tip := [2]int{loc, par[1]}
+			if pstart, _ := coords.Arm(model, sc.X, 0); par[0] == pstart {
+				tip = [2]int{par[0], loc} // the tip of the p arm is at its start
 			}
-			for i := qstart + qloc; i < qstart+qlen; i++ {
-				genomemask[i/64] |= (1 << (i % 64))
+			genomemask.ClearRange(par[0], par[1])
+			if strand == 0 {
+				genomemask.SetRange(par[0], par[1])
+				genomemask.ClearRange(tip[0], tip[1])
+			} else {
+				genomemask.SetRange(tip[0], tip[1])
 			}
 		}
 	}
+	return genomemask, centromask, nil
+}
+
+// armLocation picks the place of the crossover on a chromosome arm, as an
+// offset from the start of the arm. With a recombination map the place is
+// weighted by the local recombination rate, and otherwise every bit is
+// equally likely.
+func armLocation(model *types.Model, rnd *rand.Rand, sex int, chrom int, arm int) int {
+	_, length := coords.Arm(model, chrom, arm)
+	if model.StringParameters["recombination_map_file"] == "" {
+		return rnd.IntN(length)
+	}
+	offset := 0
+	if arm == 1 {
+		_, offset = coords.Arm(model, chrom, 0)
+	}
+	cumulative := model.GeneticMap[sex][chrom]
+	from, to := cumulative[offset], cumulative[offset+length]
+	if to <= from {
+		return rnd.IntN(length)
+	}
+	bit := crossover.ToBit(cumulative, from+rnd.Float64()*(to-from))
+	return min(max(bit-offset, 0), length-1)
+}
 
-	if sex == 0 {
-		// males don't inherit the father's X chromosome
-		xstart := model.ChromosomeArms[23][0][0]
-		xend := model.ChromosomeArms[23][1][0] + model.ChromosomeArms[23][1][1]
-		for i := xstart; i < xend; i++ {
-			genomemask[i/64] &^= (1 << (i % 64))
+// geneConversion adds non-crossover gene conversion to a chromosome's mask.
+// The number of tracts is Poisson with mean gene_conversion_rate per bit, and
+// over each tract the gamete takes the other parental copy. A tract starts
+// anywhere on the chromosome and is cut short at its end.
+func geneConversion(model *types.Model, rnd *rand.Rand, genomemask genome.Haplotype, chrom int) {
+	rate := model.Parameters["gene_conversion_rate"]
+	if rate <= 0 {
+		return
+	}
+	length := coords.ChromosomeLength(model, chrom)
+	poisson := distuv.Poisson{Lambda: rate * float64(length), Src: rnd}
+	for n := int(poisson.Rand()); n > 0; n-- {
+		from := rnd.IntN(length)
+		to := min(from+tractLength(model, rnd), length)
+		coords.Ranges(model, chrom, from, to, genomemask.FlipRange)
+	}
+}
+
+// tractLength draws the length in bits of a gene conversion tract, with mean
+// conversion_tract_length: geometric (at least 1) or fixed.
+func tractLength(model *types.Model, rnd *rand.Rand) int {
+	mean := model.Parameters["conversion_tract_length"]
+	if model.StringParameters["conversion_tract_distribution"] == "fixed" {
+		return int(mean)
+	}
+	length := 1
+	for rnd.Float64() >= 1/mean {
+		length++
+	}
+	return length
+}
+
+// applyBreakpoints sets the mask for a chromosome that starts, at the tip of
+// the p arm, on the given copy (strand) and switches copy at each breakpoint (a bit
+// offset along the chromosome, p arm first). It returns the copy at the
+// centromere.
+func applyBreakpoints(model *types.Model, genomemask genome.Haplotype, chrom int, strand int, breakpoints []int) int {
+	_, plen := coords.Arm(model, chrom, 0)
+	length := coords.ChromosomeLength(model, chrom)
+	centromereCopy := strand
+	from := 0
+	for i := 0; i <= len(breakpoints); i++ {
+		to := length
+		if i < len(breakpoints) {
+			to = breakpoints[i]
 		}
+		if from <= plen && plen < to {
+			centromereCopy = strand
+		}
+		if strand == 0 {
+			coords.Ranges(model, chrom, from, to, genomemask.SetRange)
+		}
+		from = to
+		strand = 1 - strand
 	}
-	return genomemask, centromask
+	return centromereCopy
 }
 
 // Meiosis simulates genetic recombination during gamete formation.
@@ -231,98 +534,56 @@ func createMask(model *types.Model, sex int) ([]uint64, uint64) {
 // two parental chromosome copies, the child can get a haploid, recombined
 // version of a parent's genome. This happens once for the father and once for
 // the mother, so chromosomes[child][0] = paternal inheritance and
-// chromosomes[child][1] = maternal inheritance
-
-func meiosis(pop *types.Pop, mask []uint64, parent int, child int, copy int) {
-	parentCopy0 := pop.Chromosomes[parent][0]
-	parentCopy1 := pop.Chromosomes[parent][1]
-	childCopy := make([]uint64, len(mask))
-	for i := 0; i < len(mask); i++ {
-		childCopy[i] = (mask[i] & parentCopy0[i]) | (^mask[i] & parentCopy1[i])
-	}
-	pop.Chromosomes[child][copy] = childCopy
-}
+// chromosomes[child][1] = maternal inheritance. Bits on a sex chromosome the
+// child does not carry on that strand are cleared.
 
-// countContiguousBlocks counts blocks of contiguous set bits
-func countContiguousBlocks(model *types.Model, pop *types.Pop, ind int, copy int) int {
-	blockCount := 0
-	genomestring := uint64ArrayToBitString(pop.Chromosomes[ind][copy])
-	for chrom := 1; chrom < len(model.ChromosomeArms); chrom++ {
-		pstart := model.ChromosomeArms[chrom][0][0]
-		plen := model.ChromosomeArms[chrom][0][1]
-		if pstart+plen <= len(genomestring) {
-			psegment := genomestring[pstart : pstart+plen]
-			blockCount += countBlocksInRange(psegment)
-		}
-
-		qstart := model.ChromosomeArms[chrom][1][0]
-		qlen := model.ChromosomeArms[chrom][1][1]
-		if qstart+qlen <= len(genomestring) {
-			qsegment := genomestring[qstart : qstart+qlen]
-			blockCount += countBlocksInRange(qsegment)
-		}
-	}
-	return blockCount
+func meiosis(pop *types.Pop, mask genome.Haplotype, carried genome.Haplotype, parent int) genome.Haplotype {
+	return genome.And(genome.Recombine(mask, pop.Chromosomes[parent][0], pop.Chromosomes[parent][1]), carried)
 }
 
-func countBlocksInRange(genomesegment string) int {
-	blocks := strings.Split(genomesegment, "0")
+// countContiguousBlocks counts blocks of contiguous set bits, arm by arm
+func countContiguousBlocks(model *types.Model, haplotype genome.Haplotype) int {
 	blockCount := 0
-	for _, block := range blocks {
-		if len(block) > 0 {
-			blockCount++
+	for chrom := 1; chrom <= len(model.ChromosomeArms); chrom++ {
+		for arm := 0; arm <= 1; arm++ {
+			start, length := coords.Arm(model, chrom, arm)
+			blockCount += haplotype.Blocks(start, start+length)
 		}
 	}
 	return blockCount
 }
 
-func uint64ArrayToBitString(genomesegment []uint64) string {
-	var bitString strings.Builder
-	for _, value := range genomesegment {
-		bitString.WriteString(fmt.Sprintf("%064b", value))
+// inheritCentromeres works out which seed centromeres the child inherits and
+// how many there are. The centromeres are nil if there are none.
+func inheritCentromeres(model *types.Model, pop *types.Pop, centsmask1 uint64, centsmask2 uint64, childSex int, dad int, mom int) ([]uint64, int) {
+	centromeres := make([]uint64, 2)
+	dadCents, momCents := pop.Centromeres[dad], pop.Centromeres[mom]
+	if dadCents == nil {
+		dadCents = []uint64{0, 0}
 	}
-	return bitString.String()
-}
-
-func inheritCentromeres(model *types.Model, pop *types.Pop, centsmask1 uint64, centsmask2 uint64, dad int, mom int, child int) {
-
-	if pop.IndData[dad]["num_centromeres"] > 0 || pop.IndData[mom]["num_centromeres"] > 0 {
-		pop.Centromeres[child] = make([]uint64, 2)
-		for i := 0; i < len(model.ChromosomeArms); i++ {
-			if centsmask1&(1<<i) == 0 {
-				pop.Centromeres[child][0] |= (pop.Centromeres[dad][0] & (1 << i))
-			} else {
-				pop.Centromeres[child][0] |= (pop.Centromeres[dad][1] & (1 << i))
-			}
-			if centsmask2&(1<<i) == 0 {
-				pop.Centromeres[child][1] |= (pop.Centromeres[mom][0] & (1 << i))
-			} else {
-				pop.Centromeres[child][1] |= (pop.Centromeres[mom][1] & (1 << i))
-			}
+	if momCents == nil {
+		momCents = []uint64{0, 0}
+	}
+	for i := 1; i <= len(model.ChromosomeArms); i++ {
+		// as in meiosis, a set mask bit means the centromere comes from copy 0
+		if centsmask1&(1<<i) != 0 {
+			centromeres[0] |= (dadCents[0] & (1 << i))
+		} else {
+			centromeres[0] |= (dadCents[1] & (1 << i))
 		}
-
-		centromereCount := countSetBitsSingleVar(pop.Centromeres[child][0])
-		centromereCount += countSetBitsSingleVar(pop.Centromeres[child][1])
-		pop.IndData[child]["num_centromeres"] = centromereCount
-		if centromereCount == 0 {
-			delete(pop.Centromeres, child)
+		if centsmask2&(1<<i) != 0 {
+			centromeres[1] |= (momCents[0] & (1 << i))
+		} else {
+			centromeres[1] |= (momCents[1] & (1 << i))
 		}
 	}
-}
 
-func countSetBits(bits []uint64) int {
-	count := 0
-	for _, b := range bits {
-		count += countSetBitsSingleVar(b)
-	}
-	return count
-}
+	centromeres[0] &= model.SexChromosomes.Cents[childSex][0]
+	centromeres[1] &= model.SexChromosomes.Cents[childSex][1]
 
-func countSetBitsSingleVar(n uint64) int {
-	count := 0
-	for n > 0 {
-		count += int(n & 1)
-		n >>= 1
+	centromereCount := bits.OnesCount64(centromeres[0]) + bits.OnesCount64(centromeres[1])
+	if centromereCount == 0 {
+		return nil, 0
 	}
-	return count
+	return centromeres, centromereCount
 }
@@ -0,0 +1,44 @@
+package birth
+
+import (
+	"drift/types"
+	"slices"
+	"testing"
+)
+
+func TestInheritCentromeres(t *testing.T) {
+	const dad, mom = 1, 2
+	model := &types.Model{
+		ChromosomeArms: map[int]map[int][]int{1: nil, 2: nil},
+	}
+	for sex := 0; sex <= 1; sex++ {
+		model.SexChromosomes.Cents[sex] = [2]uint64{^uint64(0), ^uint64(0)}
+	}
+	// each parent carries chromosome 1's centromere on copy 0 and
+	// chromosome 2's on copy 1, so the result shows which copy was taken
+	pop := &types.Pop{Centromeres: map[int][]uint64{
+		dad: {1 << 1, 1 << 2},
+		mom: {1 << 1, 1 << 2},
+	}}
+	tests := []struct {
+		name      string
+		dadMask   uint64
+		momMask   uint64
+		want      []uint64
+		wantCount int
+	}{
+		{"copy 0 from both", 1<<1 | 1<<2, 1<<1 | 1<<2, []uint64{1 << 1, 1 << 1}, 2},
+		{"copy 1 from both", 0, 0, []uint64{1 << 2, 1 << 2}, 2},
+		{"copy 0 from dad, copy 1 from mom", 1<<1 | 1<<2, 0, []uint64{1 << 1, 1 << 2}, 2},
+		{"mixed copies per chromosome", 1 << 1, 1 << 2, []uint64{1<<1 | 1<<2, 0}, 2},
+		{"no centromeres inherited", 1 << 2, 1 << 2, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, count := inheritCentromeres(model, pop, tt.dadMask, tt.momMask, 0, dad, mom)
+			if !slices.Equal(got, tt.want) || count != tt.wantCount {
+				t.Errorf("inheritCentromeres = %b, %d; want %b, %d", got, count, tt.want, tt.wantCount)
+			}
+		})
+	}
+}
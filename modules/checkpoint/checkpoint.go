@@ -0,0 +1,103 @@
+package checkpoint
+
+import (
+	"bufio"
+	"drift/types"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Every checkpoint file starts with these bytes, followed by the format version.
+const magic = "DRIFTCKP"
+
+// Version of the checkpoint format. Bump this whenever a change to types.Model
+// or types.Pop means older checkpoints can no longer be resumed correctly.
+const Version uint32 = 13
+
+// Everything needed to carry on a model from the end of a given year. Model
+// is the run in progress, which events may have changed, and Base is the model
+// as it was loaded, which the runs after it start from.
+type checkpoint struct {
+	Run   int
+	Year  int
+	Base  *types.Model
+	Model *types.Model
+	Pop   *types.Pop
+}
+
+// Filename returns the name of the checkpoint file for a model.
+func Filename(modelName string) string {
+	return fmt.Sprintf("results/%s.checkpoint", modelName)
+}
+
+// Save writes the model and population, as they stand at the end of the given
+// run and year, to a checkpoint file, along with the base model the run was
+// started from. The file is written under a temporary
+// name and then renamed, so a crash part way through leaves the previous
+// checkpoint intact.
+func Save(filename string, base *types.Model, model *types.Model, pop *types.Pop, run int, year int) error {
+	tmpname := filename + ".tmp"
+	file, err := os.Create(tmpname)
+	if err != nil {
+		return fmt.Errorf("could not create checkpoint %s: %w", tmpname, err)
+	}
+
+	writer := bufio.NewWriter(file)
+	err = write(writer, checkpoint{Run: run, Year: year, Base: base, Model: model, Pop: pop})
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpname)
+		return fmt.Errorf("could not write checkpoint %s: %w", tmpname, err)
+	}
+	return os.Rename(tmpname, filename)
+}
+
+func write(w io.Writer, c checkpoint) error {
+	if _, err := io.WriteString(w, magic); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, Version); err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(c)
+}
+
+// Load reads a checkpoint file and returns the base model, the model and
+// population of the run in progress, and the run and year at whose end it was
+// taken.
+func Load(filename string) (*types.Model, *types.Model, *types.Pop, int, int, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, nil, 0, 0, fmt.Errorf("could not open checkpoint %s: %w", filename, err)
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(reader, header); err != nil || string(header) != magic {
+		return nil, nil, nil, 0, 0, fmt.Errorf("%s is not a checkpoint file", filename)
+	}
+	var version uint32
+	if err := binary.Read(reader, binary.LittleEndian, &version); err != nil {
+		return nil, nil, nil, 0, 0, fmt.Errorf("could not read checkpoint %s: %w", filename, err)
+	}
+	if version != Version {
+		return nil, nil, nil, 0, 0, fmt.Errorf("checkpoint %s is version %d, this program reads version %d", filename, version, Version)
+	}
+
+	// Gob leaves out empty maps, so decode into a model and population whose
+	// maps already exist.
+	c := checkpoint{Base: types.NewModel(), Model: types.NewModel(), Pop: types.NewPop()}
+	if err := gob.NewDecoder(reader).Decode(&c); err != nil {
+		return nil, nil, nil, 0, 0, fmt.Errorf("could not read checkpoint %s: %w", filename, err)
+	}
+	return c.Base, c.Model, c.Pop, c.Run, c.Year, nil
+}
@@ -1,18 +1,27 @@
 package chromosomeloader
 
 import (
+	"drift/modules/coords"
 	"drift/modules/csvutils"
+	"drift/modules/genome"
 	"drift/types"
+	"fmt"
+	"slices"
 )
 
-// Name of the CSV file containing the chromosome arms.
-const myFileName = "chromosome_data.csv"
-
-// Load the chromosome arms from a CSV file and populate the model's ChromosomeArms map.
+// Load the chromosome arms from the CSV file named by the chromosome_file
+// parameter and populate the model's ChromosomeArms map. The chromosomes must
+// be numbered from 1, each with a p arm (0) and a q arm (1), and no two arms
+// may overlap.
+//
+// Two optional columns describe the sex chromosomes. Type is A for an
+// autosome (the default), or X, Y, Z or W. PAR is the number of bits at the
+// tip of an X or Z arm that are pseudo-autosomal, pairing with the Y or W.
+// The Y or W itself should only hold its sex-specific part.
 func LoadChromosomeArms(model *types.Model, configRoot string) error {
 	// Load the CSV file
 	csvLoader := csvutils.CSVLoader{
-		FileName:   myFileName,
+		FileName:   model.StringParameters["chromosome_file"],
 		Dir:        configRoot,
 		MinRecords: 2,
 	}
@@ -23,6 +32,8 @@ func LoadChromosomeArms(model *types.Model, configRoot string) error {
 
 	// Skip the header row and process each record
 	var totallen int
+	chromTypes := make(map[int]string)
+	var pars [][3]int // chromosome, arm and length of each pseudo-autosomal region
 	for _, record := range records[1:] {
 		// Ensure the record has at least 4 fields
 		err := csvLoader.CheckRecord(record, 4)
@@ -56,12 +67,178 @@ func LoadChromosomeArms(model *types.Model, configRoot string) error {
 		if model.ChromosomeArms[chromosome][arm] == nil {
 			model.ChromosomeArms[chromosome][arm] = make([]int, 2)
 		}
-		model.ChromosomeArms[chromosome][arm][0] = start * int(model.Parameters["multiplier"])
-		model.ChromosomeArms[chromosome][arm][1] = length * int(model.Parameters["multiplier"])
+		model.ChromosomeArms[chromosome][arm][0] = coords.Bins(model, start)
+		model.ChromosomeArms[chromosome][arm][1] = coords.Bins(model, length)
 		totallen += model.ChromosomeArms[chromosome][arm][1]
+
+		// Sex chromosome columns
+		chromType := "A"
+		if len(record) > 4 && record[4] != "" {
+			chromType = record[4]
+		}
+		if !slices.Contains([]string{"A", "X", "Y", "Z", "W"}, chromType) {
+			return csvutils.ErrInvalidField{CSVLoader: csvLoader, Record: record, Field: 4, Message: "The type should be A, X, Y, Z or W"}
+		}
+		if previous, ok := chromTypes[chromosome]; ok && previous != chromType {
+			return csvutils.ErrInvalidField{CSVLoader: csvLoader, Record: record, Field: 4, Message: "Both arms of a chromosome should have the same type"}
+		}
+		chromTypes[chromosome] = chromType
+		if len(record) > 5 && record[5] != "" {
+			par, err := csvLoader.Atoi(record, 5)
+			if err != nil {
+				return err
+			}
+			if par < 0 || par > length {
+				return csvutils.ErrInvalidField{CSVLoader: csvLoader, Record: record, Field: 5, Message: "The PAR should be between 0 and the length of the arm"}
+			}
+			if par > 0 {
+				pars = append(pars, [3]int{chromosome, arm, coords.Bins(model, par)})
+			}
+		}
 	}
 
 	model.FreeParameters["numbits"] = totallen
 
+	err = checkArms(model, csvLoader)
+	if err != nil {
+		return err
+	}
+	err = setSexChromosomes(model, csvLoader, chromTypes, pars)
+	if err != nil {
+		return err
+	}
+	SetUniformGeneticMap(model)
+	return nil
+}
+
+// SetUniformGeneticMap gives every chromosome, in both sexes, the same
+// recombination rate along its whole length: recombination_rate cM per Mb,
+// where a bit is 1/multiplier Mb.
+func SetUniformGeneticMap(model *types.Model) {
+	perBit := model.Parameters["recombination_rate"] / 100 * coords.Mb(model, 1)
+	geneticMap := make(map[int][]float64)
+	for chrom := 1; chrom <= len(model.ChromosomeArms); chrom++ {
+		length := coords.ChromosomeLength(model, chrom)
+		cumulative := make([]float64, length+1)
+		for bit := range cumulative {
+			cumulative[bit] = float64(bit) * perBit
+		}
+		geneticMap[chrom] = cumulative
+	}
+	model.GeneticMap = [2]map[int][]float64{geneticMap, geneticMap}
+}
+
+// setSexChromosomes works out the sex chromosome system from the chromosome
+// types, and which bits and centromeres each sex carries on each strand.
+// Hermaphrodites have no sex chromosomes, so for them every chromosome is
+// treated as an autosome.
+func setSexChromosomes(model *types.Model, csvLoader csvutils.CSVLoader, chromTypes map[int]string, pars [][3]int) error {
+	found := make(map[string]int)
+	for chrom := 1; chrom <= len(model.ChromosomeArms); chrom++ {
+		chromType := chromTypes[chrom]
+		if chromType == "A" {
+			continue
+		}
+		if _, ok := found[chromType]; ok {
+			return fmt.Errorf("Error: %s has more than one %s chromosome", csvLoader.FileName, chromType)
+		}
+		found[chromType] = chrom
+	}
+
+	sc := types.SexChromosomes{System: "none"}
+	switch {
+	case len(found) == 0:
+	case len(found) == 2 && found["X"] > 0 && found["Y"] > 0:
+		sc = types.SexChromosomes{System: "XY", X: found["X"], Y: found["Y"]}
+	case len(found) == 1 && found["X"] > 0:
+		sc = types.SexChromosomes{System: "X0", X: found["X"]}
+	case len(found) == 2 && found["Z"] > 0 && found["W"] > 0:
+		sc = types.SexChromosomes{System: "ZW", X: found["Z"], Y: found["W"]}
+	default:
+		return fmt.Errorf("Error: the sex chromosomes in %s should be X and Y, X alone, or Z and W", csvLoader.FileName)
+	}
+
+	for _, par := range pars {
+		chrom, arm, length := par[0], par[1], par[2]
+		if chrom != sc.X || sc.Y == 0 {
+			return fmt.Errorf("Error: in %s, only the X or Z of an XY or ZW system can have a PAR (chromosome %d)", csvLoader.FileName, chrom)
+		}
+		start, armLength := coords.Arm(model, chrom, arm)
+		if arm == 0 { // the tip of the p arm is at its start, and of the q arm at its end
+			sc.PAR = append(sc.PAR, [2]int{start, start + length})
+		} else {
+			sc.PAR = append(sc.PAR, [2]int{start + armLength - length, start + armLength})
+		}
+	}
+	if model.StringParameters["mating_system"] == "hermaphrodite" {
+		sc = types.SexChromosomes{System: "none"}
+	}
+
+	// Everyone carries everything, except for the sex chromosomes
+	numbits := model.FreeParameters["numbits"]
+	var allCents uint64
+	for chrom := 1; chrom <= len(model.ChromosomeArms); chrom++ {
+		allCents |= 1 << (chrom % 64)
+	}
+	for sex := 0; sex <= 1; sex++ {
+		for strand := 0; strand <= 1; strand++ {
+			sc.Carried[sex][strand] = genome.RangeMask(numbits, 0, numbits)
+			sc.Cents[sex][strand] = allCents
+		}
+	}
+	drop := func(sex, strand, chrom int, keepPAR bool) {
+		if chrom == 0 {
+			return
+		}
+		for arm := 0; arm <= 1; arm++ {
+			start, length := coords.Arm(model, chrom, arm)
+			sc.Carried[sex][strand].ClearRange(start, start+length)
+		}
+		if keepPAR {
+			for _, par := range sc.PAR {
+				sc.Carried[sex][strand].SetRange(par[0], par[1])
+			}
+		}
+		sc.Cents[sex][strand] &^= 1 << (chrom % 64)
+	}
+	if het := sc.Heterogametic(); het >= 0 {
+		// The homogametic sex has two Xs and no Y
+		drop(1-het, 0, sc.Y, false)
+		drop(1-het, 1, sc.Y, false)
+		// The heterogametic sex has the Y (or nothing) on the strand from the
+		// parent of the same sex, and the X on the other. The PAR is on both.
+		drop(het, het, sc.X, true)
+		drop(het, 1-het, sc.Y, false)
+	}
+
+	model.SexChromosomes = sc
+	return nil
+}
+
+// checkArms makes sure that the arms are complete and lie side by side
+// within the genome.
+func checkArms(model *types.Model, csvLoader csvutils.CSVLoader) error {
+	type arm struct{ chrom, arm, start, end int }
+	var arms []arm
+	for chrom := 1; chrom <= len(model.ChromosomeArms); chrom++ {
+		for a := 0; a <= 1; a++ {
+			if model.ChromosomeArms[chrom][a] == nil {
+				return fmt.Errorf("Error: %s has no arm %d for chromosome %d", csvLoader.FileName, a, chrom)
+			}
+			start, length := coords.Arm(model, chrom, a)
+			arms = append(arms, arm{chrom, a, start, start + length})
+		}
+	}
+	slices.SortFunc(arms, func(x, y arm) int { return x.start - y.start })
+	for i, x := range arms {
+		if x.start < 0 || x.end > model.FreeParameters["numbits"] {
+			return fmt.Errorf("Error: in %s, chromosome %d arm %d lies outside the genome of %d bits",
+				csvLoader.FileName, x.chrom, x.arm, model.FreeParameters["numbits"])
+		}
+		if i > 0 && x.start < arms[i-1].end {
+			return fmt.Errorf("Error: in %s, chromosome %d arm %d overlaps chromosome %d arm %d",
+				csvLoader.FileName, x.chrom, x.arm, arms[i-1].chrom, arms[i-1].arm)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,72 @@
+package chromosomeloader
+
+import (
+	"drift/types"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The chromosome files shipped in static must load as they are.
+func TestStaticFiles(t *testing.T) {
+	tests := []struct {
+		file        string
+		chromosomes int
+		system      string
+	}{
+		{"chromosome_data.csv", 24, "XY"},
+		{"Pisum_chromosome_data.csv", 7, "none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			model := newModel(tt.file)
+			if err := LoadChromosomeArms(model, filepath.Join("..", "..", "static")); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(model.ChromosomeArms); got != tt.chromosomes {
+				t.Fatalf("loaded %d chromosomes, want %d", got, tt.chromosomes)
+			}
+			if got := model.SexChromosomes.System; got != tt.system {
+				t.Fatalf("sex chromosome system %q, want %q", got, tt.system)
+			}
+		})
+	}
+}
+
+func TestArms(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    string
+		wantErr bool
+	}{
+		{"side by side", "1,0,0,2,,\n1,1,2,3,,\n2,0,5,1,,\n2,1,6,1,,\n", false},
+		{"overlapping", "1,0,0,2,,\n1,1,1,3,,\n2,0,5,1,,\n2,1,6,1,,\n", true},
+		{"outside the genome", "1,0,0,2,,\n1,1,2,3,,\n2,0,5,1,,\n2,1,7,1,,\n", true},
+		{"missing arm", "1,0,0,2,,\n1,1,2,3,,\n2,0,5,1,,\n", true},
+		{"bad type", "1,0,0,2,Q,\n1,1,2,3,Q,\n", true},
+		{"mixed types", "1,0,0,2,X,\n1,1,2,3,A,\n", true},
+		{"X and Y", "1,0,0,2,X,1\n1,1,2,3,X,\n2,0,5,1,Y,\n2,1,6,1,Y,\n", false},
+		{"PAR longer than the arm", "1,0,0,2,X,3\n1,1,2,3,X,\n2,0,5,1,Y,\n2,1,6,1,Y,\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			err := os.WriteFile(filepath.Join(dir, "arms.csv"), []byte("Chromosome,Arm,Start,Length,Type,PAR\n"+tt.rows), 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = LoadChromosomeArms(newModel("arms.csv"), dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadChromosomeArms error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func newModel(file string) *types.Model {
+	model := types.NewModel()
+	model.StringParameters["chromosome_file"] = file
+	model.Parameters["multiplier"] = 1
+	model.Parameters["recombination_rate"] = 1
+	return model
+}
@@ -0,0 +1,61 @@
+package coords
+
+import "drift/types"
+
+// The genome is simulated as a row of bins, the bits of a haplotype. The
+// chromosome file gives the arms in Mb and each Mb is split into multiplier
+// bins, so a bin holds 1,000,000/multiplier base pairs. Base-pair positions
+// count from the start of the genome, in the same order as the bins.
+
+// BasesPerMb is the number of base pairs in a megabase.
+const BasesPerMb = 1000000
+
+func multiplier(model *types.Model) int {
+	return int(model.Parameters["multiplier"])
+}
+
+// Bins converts a length or position in Mb, as given in the chromosome file,
+// into bins.
+func Bins(model *types.Model, mb int) int {
+	return mb * multiplier(model)
+}
+
+// Mb converts a position or length in bins into Mb.
+func Mb(model *types.Model, bins float64) float64 {
+	return bins / float64(multiplier(model))
+}
+
+// GenomeLength returns the length of the genome in base pairs.
+func GenomeLength(model *types.Model) int {
+	return model.FreeParameters["numbits"] * BasesPerMb / multiplier(model)
+}
+
+// Bin returns the bin that holds a base-pair position.
+func Bin(model *types.Model, position int) int {
+	return position * multiplier(model) / BasesPerMb
+}
+
+// Arm returns the first bin of a chromosome arm (0 = p, 1 = q) and its length
+// in bins.
+func Arm(model *types.Model, chrom int, arm int) (int, int) {
+	return model.ChromosomeArms[chrom][arm][0], model.ChromosomeArms[chrom][arm][1]
+}
+
+// ChromosomeLength returns the length of a chromosome in bins.
+func ChromosomeLength(model *types.Model, chrom int) int {
+	return model.ChromosomeArms[chrom][0][1] + model.ChromosomeArms[chrom][1][1]
+}
+
+// Ranges calls fn with the bins [start, end) of the genome that make up the
+// stretch from offset from to offset to along a chromosome, p arm first. The
+// stretch is split at the centromere if it crosses it.
+func Ranges(model *types.Model, chrom int, from int, to int, fn func(start int, end int)) {
+	for arm, offset := 0, 0; arm <= 1; arm++ {
+		start, length := Arm(model, chrom, arm)
+		lo, hi := max(from-offset, 0), min(to-offset, length)
+		if lo < hi {
+			fn(start+lo, start+hi)
+		}
+		offset += length
+	}
+}
@@ -0,0 +1,107 @@
+package coords
+
+import (
+	"drift/types"
+	"slices"
+	"testing"
+)
+
+// newModel returns a model of two chromosomes, with arms of 2 and 3 Mb and of
+// 1 and 4 Mb, and the given multiplier. The second chromosome's arms are
+// stored out of order, q before p.
+func newModel(multiplier int) *types.Model {
+	model := types.NewModel()
+	model.Parameters["multiplier"] = float64(multiplier)
+	arms := [][2][2]int{{{0, 2}, {2, 3}}, {{9, 1}, {5, 4}}}
+	for i, chrom := range arms {
+		model.ChromosomeArms[i+1] = map[int][]int{
+			0: {Bins(model, chrom[0][0]), Bins(model, chrom[0][1])},
+			1: {Bins(model, chrom[1][0]), Bins(model, chrom[1][1])},
+		}
+	}
+	model.FreeParameters["numbits"] = Bins(model, 10)
+	return model
+}
+
+func TestBin(t *testing.T) {
+	tests := []struct {
+		name       string
+		multiplier int
+		position   int
+		want       int
+	}{
+		{"first base", 1, 0, 0},
+		{"last base of the first bin", 1, BasesPerMb - 1, 0},
+		{"first base of the second bin", 1, BasesPerMb, 1},
+		{"last base of the genome", 1, 10*BasesPerMb - 1, 9},
+		{"multiplier 4, last base of a bin", 4, BasesPerMb/4 - 1, 0},
+		{"multiplier 4, first base of a bin", 4, BasesPerMb / 4, 1},
+		{"multiplier 4, last base of the genome", 4, 10*BasesPerMb - 1, 39},
+		{"multiplier 3, bins of uneven size", 3, 333334, 1},
+		{"multiplier 3, before the bin boundary", 3, 333333, 0},
+		{"multiplier 3, last base of the genome", 3, 10*BasesPerMb - 1, 29},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Bin(newModel(tt.multiplier), tt.position); got != tt.want {
+				t.Fatalf("Bin(%d) = %d, want %d", tt.position, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLengths(t *testing.T) {
+	tests := []struct {
+		multiplier   int
+		genomeLength int
+		chromosome2  int
+		mb           float64
+	}{
+		{1, 10 * BasesPerMb, 5, 1},
+		{4, 10 * BasesPerMb, 20, 0.25},
+		{3, 10 * BasesPerMb, 15, 1.0 / 3},
+	}
+	for _, tt := range tests {
+		model := newModel(tt.multiplier)
+		if got := GenomeLength(model); got != tt.genomeLength {
+			t.Errorf("multiplier %d: GenomeLength() = %d, want %d", tt.multiplier, got, tt.genomeLength)
+		}
+		if got := ChromosomeLength(model, 2); got != tt.chromosome2 {
+			t.Errorf("multiplier %d: ChromosomeLength(2) = %d, want %d", tt.multiplier, got, tt.chromosome2)
+		}
+		if got := Mb(model, 1); got != tt.mb {
+			t.Errorf("multiplier %d: Mb(1) = %v, want %v", tt.multiplier, got, tt.mb)
+		}
+	}
+}
+
+func TestRanges(t *testing.T) {
+	tests := []struct {
+		name  string
+		chrom int
+		from  int
+		to    int
+		want  [][2]int
+	}{
+		{"within the p arm", 1, 0, 2, [][2]int{{0, 2}}},
+		{"within the q arm", 1, 3, 5, [][2]int{{3, 5}}},
+		{"across the centromere", 1, 1, 4, [][2]int{{1, 2}, {2, 4}}},
+		{"whole chromosome", 1, 0, 5, [][2]int{{0, 2}, {2, 5}}},
+		{"past the end", 1, 4, 9, [][2]int{{4, 5}}},
+		{"empty", 1, 3, 3, nil},
+		{"arms out of order, p arm", 2, 0, 1, [][2]int{{9, 10}}},
+		{"arms out of order, across the centromere", 2, 0, 3, [][2]int{{9, 10}, {5, 7}}},
+		{"arms out of order, q arm", 2, 2, 5, [][2]int{{6, 9}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][2]int
+			Ranges(newModel(1), tt.chrom, tt.from, tt.to, func(start int, end int) {
+				got = append(got, [2]int{start, end})
+			})
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Ranges(%d, %d, %d) = %v, want %v", tt.chrom, tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package crossover
+
+import (
+	"drift/types"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sort"
+
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// How far before the start of a chromosome, in Morgans, the gamma model
+// starts laying down chiasmata, so that the first one is placed as if the
+// process had been running all along.
+const burnIn = 5.0
+
+// Draw places the crossovers for one gamete on a chromosome, from a parent of
+// the given sex. Chiasmata are laid down on the bivalent, either at random
+// (poisson) or spaced by gamma-distributed genetic distances (gamma, where an
+// interference of 1 is the same as poisson and larger values space them more
+// evenly), and each ends up in the gamete with probability one half. With
+// obligate_chiasma set, a bivalent with none is given one at a uniformly
+// chosen position, though the gamete may still inherit none. The crossovers are returned as sorted bit
+// offsets along the chromosome, p arm first, at which the gamete switches
+// from one parental copy to the other. It is an error for the chromosome to
+// have no genetic map.
+func Draw(model *types.Model, rnd *rand.Rand, sex int, chrom int) ([]int, error) {
+	cumulative := model.GeneticMap[sex][chrom]
+	if len(cumulative) == 0 {
+		return nil, fmt.Errorf("Error: chromosome %d has no genetic map", chrom)
+	}
+	length := cumulative[len(cumulative)-1]
+	if length <= 0 {
+		return nil, nil
+	}
+
+	var chiasmata []float64
+	if model.StringParameters["crossover_model"] == "gamma" {
+		nu := model.Parameters["interference"]
+		gamma := distuv.Gamma{Alpha: nu, Beta: 2 * nu, Src: rnd}
+		for x := -burnIn + gamma.Rand(); x < length; x += gamma.Rand() {
+			if x >= 0 {
+				chiasmata = append(chiasmata, x)
+			}
+		}
+	} else {
+		poisson := distuv.Poisson{Lambda: 2 * length, Src: rnd}
+		for n := int(poisson.Rand()); n > 0; n-- {
+			chiasmata = append(chiasmata, rnd.Float64()*length)
+		}
+	}
+	// a bivalent left without a chiasma gets one anywhere along it, rather than
+	// being drawn again, which could take a very long time on a short map
+	if len(chiasmata) == 0 && model.Parameters["obligate_chiasma"] == 1 {
+		chiasmata = append(chiasmata, rnd.Float64()*length)
+	}
+
+	// each chiasma involves one of the gamete's two chromatids
+	var breakpoints []int
+	for _, x := range chiasmata {
+		if rnd.IntN(2) == 0 {
+			breakpoints = append(breakpoints, ToBit(cumulative, x))
+		}
+	}
+	slices.Sort(breakpoints)
+	return breakpoints, nil
+}
+
+// ToBit converts a genetic position along a chromosome into the bit offset
+// where it falls, given the cumulative genetic length at each bit boundary.
+func ToBit(cumulative []float64, x float64) int {
+	return max(sort.SearchFloat64s(cumulative, x), 1) - 1
+}
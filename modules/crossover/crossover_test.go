@@ -0,0 +1,119 @@
+package crossover
+
+import (
+	"drift/types"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// uniform returns a cumulative genetic map of bits bits, each perBit Morgans
+// long.
+func uniform(bits int, perBit float64) []float64 {
+	cumulative := make([]float64, bits+1)
+	for bit := range cumulative {
+		cumulative[bit] = float64(bit) * perBit
+	}
+	return cumulative
+}
+
+func TestDraw(t *testing.T) {
+	tests := []struct {
+		name       string
+		geneticMap []float64
+		model      string
+		obligate   bool
+		wantErr    bool
+		wantNone   bool
+	}{
+		{"no map", nil, "poisson", false, true, false},
+		{"zero length", uniform(10, 0), "poisson", false, false, true},
+		{"poisson", uniform(100, 0.01), "poisson", false, false, false},
+		{"gamma", uniform(100, 0.01), "gamma", false, false, false},
+		{"obligate chiasma", uniform(100, 0.001), "poisson", true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := types.NewModel()
+			model.StringParameters["crossover_model"] = tt.model
+			model.Parameters["interference"] = 2
+			if tt.obligate {
+				model.Parameters["obligate_chiasma"] = 1
+			}
+			if tt.geneticMap != nil {
+				model.GeneticMap = [2]map[int][]float64{{1: tt.geneticMap}, {1: tt.geneticMap}}
+			}
+			rnd := rand.New(rand.NewPCG(1, 2))
+			for i := 0; i < 100; i++ {
+				breakpoints, err := Draw(model, rnd, 0, 1)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Draw() error = %v, want error %v", err, tt.wantErr)
+				}
+				if tt.wantNone && len(breakpoints) > 0 {
+					t.Fatalf("Draw() = %v, want no crossovers", breakpoints)
+				}
+				if !slices.IsSorted(breakpoints) {
+					t.Fatalf("Draw() = %v, want them sorted", breakpoints)
+				}
+				for _, bit := range breakpoints {
+					if bit < 0 || bit >= len(tt.geneticMap)-1 {
+						t.Fatalf("Draw() = %v, outside the chromosome", breakpoints)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestToBit(t *testing.T) {
+	cumulative := []float64{0, 0.1, 0.1, 0.3, 0.6}
+	tests := []struct {
+		x    float64
+		want int
+	}{
+		{0, 0},
+		{0.05, 0},
+		{0.1, 0},
+		{0.2, 2},
+		{0.3, 2},
+		{0.59, 3},
+	}
+	for _, tt := range tests {
+		if got := ToBit(cumulative, tt.x); got != tt.want {
+			t.Errorf("ToBit(%v) = %d, want %d", tt.x, got, tt.want)
+		}
+	}
+}
+
+func TestObligateChiasma(t *testing.T) {
+	// a map so short that a chiasma is practically never drawn
+	geneticMap := uniform(100, 1e-12)
+	tests := []struct {
+		name     string
+		obligate float64
+		min, max int // crossovers wanted in 1000 gametes
+	}{
+		{"off", 0, 0, 0},
+		{"on", 1, 400, 600}, // one chiasma each, in the gamete half the time
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := types.NewModel()
+			model.StringParameters["crossover_model"] = "poisson"
+			model.Parameters["obligate_chiasma"] = tt.obligate
+			model.GeneticMap = [2]map[int][]float64{{1: geneticMap}, {1: geneticMap}}
+			rnd := rand.New(rand.NewPCG(1, 2))
+			total := 0
+			for i := 0; i < 1000; i++ {
+				breakpoints, err := Draw(model, rnd, 0, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				total += len(breakpoints)
+			}
+			if total < tt.min || total > tt.max {
+				t.Fatalf("Draw() gave %d crossovers in 1000 gametes, want %d to %d", total, tt.min, tt.max)
+			}
+		})
+	}
+}
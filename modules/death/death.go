@@ -1,20 +1,31 @@
 package death
 
 import (
+	"drift/modules/rng"
+	"drift/modules/selection"
+	"drift/modules/stage"
 	"drift/types"
 	"fmt"
-	"math/rand"
 	"os"
+	"slices"
 	"strings"
-	"time"
 )
 
+func init() {
+	stage.Register(stage.Death, stage.Default, stage.StageFunc(
+		func(model *types.Model, pop *types.Pop, year int) error {
+			Death(model, pop, year, model.FreeParameters["run"])
+			return nil
+		}))
+}
+
 func Death(model *types.Model, pop *types.Pop, year int, run int) int {
 
-	rand.Seed(time.Now().UnixNano())
+	rnd := model.RNG[rng.Death]
+	strategy := selection.For(model)
 	deaths := 0
 	var deadPeopleData string
-	keyList := generateKeyList(pop.IndData)
+	keyList := pop.IDs()
 
 	// Step 1: Random actuarial deaths
 	for _, ind := range keyList {
@@ -30,19 +41,16 @@ func Death(model *types.Model, pop *types.Pop, year int, run int) int {
 		// The death risk is really high at 85 already, so anyone who makes it to
 		// >= 85 has the same risk of dying each year.
 
-		age := year - pop.IndData[ind]["birth_year"]
-		ageGroup := int((float64(age)/float64(pop.IndData[ind]["lifespan"]))*model.Parameters["min_lifespan"]/5) * 5
+		person := pop.Ind(ind)
+		age := year - person.BirthYear
+		ageGroup := int((float64(age)/float64(person.Lifespan))*model.Parameters["min_lifespan"]/5) * 5
 		if ageGroup > 85 {
 			ageGroup = 85
 		}
 		deathrisk := model.DeathRisk[ageGroup]
-		die := rand.Float64() // low roll = death
-		riskModification := model.Parameters["min_lifespan"] / float64(pop.IndData[ind]["lifespan"])
-		fitness := 1.0
-		if model.Parameters["track_mutations"] == 1 {
-			fitness = float64(pop.IndData[ind]["fitness"]) / model.Parameters["mu_scale_factor"]
-		}
-		adjustedDeathRisk := deathrisk * riskModification * fitness
+		die := rnd.Float64() // low roll = death
+		riskModification := model.Parameters["min_lifespan"] / float64(person.Lifespan)
+		adjustedDeathRisk := strategy.DeathRisk(model, person, deathrisk*riskModification)
 		if die < adjustedDeathRisk {
 			if int(model.Parameters["track_dead"]) == 1 {
 				deadPersonString := personDataString(ind, pop, year, "R")
@@ -50,23 +58,55 @@ func Death(model *types.Model, pop *types.Pop, year int, run int) int {
 			}
 			RIP(ind, pop, model)
 			deaths++
-			model.Parameters["random_deaths"]++
+			pop.Tracking["random_deaths"]++
 		}
 	}
 
+	// Unrestricted probability selection: this year's newborns survive with a
+	// chance equal to their fitness
+	if strategy.Viability {
+		for _, ind := range pop.IDs() {
+			person := pop.Ind(ind)
+			if person.BirthYear != year || slices.Contains(pop.Seeds, ind) {
+				continue
+			}
+			if rnd.Float64() >= selection.Fitness(model, person) {
+				if int(model.Parameters["track_dead"]) == 1 {
+					deadPeopleData += personDataString(ind, pop, year, "R")
+				}
+				RIP(ind, pop, model)
+				deaths++
+				pop.Tracking["cull_deaths"]++
+			}
+		}
+	}
+
+	// Those culled below are picked at random, or with truncation or
+	// probability selection, the lowest ranked first
+	var ranked []int
+	nextToCull := func(keyList []int) int {
+		if strategy.Cull == selection.Random {
+			return keyList[rnd.IntN(len(keyList))]
+		}
+		if ranked == nil {
+			ranked = strategy.Rank(model, pop, rnd.Rand, nonSeeds(pop))
+		}
+		ind := ranked[0]
+		ranked = ranked[1:]
+		return ind
+	}
+
 	// Adjust max population size based on bottleneck
 	maxPopSize := int(model.Parameters["max_pop_size"])
 	if int(model.Parameters["bottleneck_start"]) <= year && int(model.Parameters["bottleneck_end"]) >= year {
 		maxPopSize = int(model.Parameters["bottleneck_size"])
 	}
 
-	// Step 2: Trim excess population by randomly culling individuals
+	// Step 2: Trim excess population by culling individuals
 	excess := len(pop.IndData) - maxPopSize
-	for excess > 0 {
-		keyList = generateKeyList(pop.IndData)
-		randomIndex := rand.Intn(len(keyList))
-		ind := keyList[randomIndex]
-		if ind == model.FreeParameters["seed"] { // Don't kill off the seed
+	for excess > 0 && !onlySeedsLeft(pop) {
+		ind := nextToCull(pop.IDs())
+		if slices.Contains(pop.Seeds, ind) { // Don't kill off the seeds
 			continue
 		}
 		if int(model.Parameters["track_dead"]) == 1 {
@@ -80,18 +120,16 @@ func Death(model *types.Model, pop *types.Pop, year int, run int) int {
 		excess = len(pop.IndData) - maxPopSize
 	}
 
-	// Step 3: Tamp down population growth rate by randomly culling individuals
-	allowedNumInds := int(float64(model.FreeParameters["last_pop_size"]) * model.Parameters["max_growth_rate"])
+	// Step 3: Tamp down population growth rate by culling individuals
+	allowedNumInds := int(float64(model.FreeParameters["last_pop_size"]) * model.ParamAt("max_growth_rate", year))
 	if allowedNumInds > int(model.Parameters["max_pop_size"]) {
 		allowedNumInds = int(model.Parameters["max_pop_size"])
 	}
 
 	diff := len(pop.IndData) - allowedNumInds
-	for diff > 0 {
-		keyList = generateKeyList(pop.IndData)
-		randomIndex := rand.Intn(len(keyList))
-		ind := keyList[randomIndex]
-		if ind == model.FreeParameters["seed"] { // Don't kill off the seed
+	for diff > 0 && !onlySeedsLeft(pop) {
+		ind := nextToCull(pop.IDs())
+		if slices.Contains(pop.Seeds, ind) { // Don't kill off the seeds
 			continue
 		}
 		if int(model.Parameters["track_dead"]) == 1 {
@@ -105,14 +143,13 @@ func Death(model *types.Model, pop *types.Pop, year int, run int) int {
 		diff = len(pop.IndData) - allowedNumInds
 	}
 
-	// Step 4: Reduce population to specified number of breeding individuals, if called for, by randomly culling individuals
+	// Step 4: Reduce population to specified number of breeding individuals, if called for, by culling individuals
 	if model.Parameters["max_breeding_inds"] > -1 {
-		keyList = generateKeyList(pop.IndData)
+		keyList = pop.IDs()
 		breeders := countBreedingIndividuals(pop, year, model)
-		for breeders > int(model.Parameters["max_breeding_inds"]) {
-			randomIndex := rand.Intn(len(keyList))
-			ind := keyList[randomIndex]
-			if ind == model.FreeParameters["seed"] { // Don't kill off the seed
+		for breeders > int(model.Parameters["max_breeding_inds"]) && !onlySeedsLeft(pop) {
+			ind := nextToCull(keyList)
+			if slices.Contains(pop.Seeds, ind) { // Don't kill off the seeds
 				continue
 			}
 			if int(model.Parameters["track_dead"]) == 1 {
@@ -137,24 +174,41 @@ func Death(model *types.Model, pop *types.Pop, year int, run int) int {
 	return deaths
 }
 
-// generateKeyList creates a slice of all individual IDs
-func generateKeyList(indData map[int]map[string]int) []int {
-	keyList := make([]int, 0, len(indData))
-	for key := range indData {
-		keyList = append(keyList, key)
+// onlySeedsLeft reports whether everyone still alive is a seed, who are never
+// culled.
+func onlySeedsLeft(pop *types.Pop) bool {
+	living := 0
+	for _, seed := range pop.Seeds {
+		if pop.Ind(seed) != nil {
+			living++
+		}
+	}
+	return living == len(pop.IndData)
+}
+
+// nonSeeds returns the IDs of everyone alive who is not a seed.
+func nonSeeds(pop *types.Pop) []int {
+	var ids []int
+	for _, ind := range pop.IDs() {
+		if !slices.Contains(pop.Seeds, ind) {
+			ids = append(ids, ind)
+		}
 	}
-	return keyList
+	return ids
 }
 
 // RIP removes a deceased individual and updates related data
 func RIP(ind int, pop *types.Pop, model *types.Model) {
-	if _, exists := pop.IndData[ind]; exists {
-		if pop.IndData[ind]["marriage_state"] > -1 {
-			spouse := pop.IndData[ind]["marriage_state"]
-			pop.IndData[spouse]["marriage_state"] = -1
+	if person := pop.Ind(ind); person != nil {
+		if person.MarriageState > -1 {
+			if spouse := pop.Ind(person.MarriageState); spouse != nil {
+				spouse.MarriageState = -1
+			}
 		}
 	}
 	delete(pop.Chromosomes, ind)
+	delete(pop.Ancestry, ind)
+	delete(pop.Descent, ind)
 	// decrement mutation counts
 	for strand := 0; strand <= 1; strand++ {
 		if mutationIDs, exists := pop.IndMutations[ind][strand]; exists {
@@ -171,44 +225,37 @@ func RIP(ind int, pop *types.Pop, model *types.Model) {
 		}
 	}
 	delete(pop.IndMutations, ind)
-	delete(pop.IndData, ind)
+	pop.Remove(ind)
 }
 
 // deadString formats individual data for death records
 func deadString(ind int, pop *types.Pop, year int) string {
 	// keeps track of deceased individuals if they are to be saved
 	var info strings.Builder
-	indInfo, exists := pop.IndData[ind]
-	if exists {
-		getValue := func(key string, defaultVal int) int {
-			if val, ok := indInfo[key]; ok {
-				return val
-			}
-			return defaultVal
-		}
+	if person := pop.Ind(ind); person != nil {
 		info.WriteString(fmt.Sprintf("%d,%d,%d,%d,%d,%d,%d,",
 			ind,
-			getValue("birth_year", -1),
+			person.BirthYear,
 			year,
-			getValue("sex", -1),
-			getValue("dad", -1),
-			getValue("mom", -1),
-			getValue("lifespan", -1),
+			person.Sex,
+			person.Dad,
+			person.Mom,
+			person.Lifespan,
 		))
 		info.WriteString(fmt.Sprintf("%d,%d,%d,%d,%d,%d,%d,%d,%d,%d,%d,%d,%d",
-			getValue("lat", -1),
-			getValue("lon", -1),
-			getValue("marriage_state", -1),
-			getValue("numbirths", -1),
-			getValue("Y_gens", -1),
-			getValue("mt_gens", -1),
-			getValue("min_genealo_gens", -1),
-			getValue("max_genealo_gens", -1),
-			getValue("allele_count", -1),
-			getValue("num_blocks", -1),
-			getValue("centromeres", -1),
-			getValue("fitness", -1),
-			getValue("mutations", -1),
+			person.Lat,
+			person.Lon,
+			person.MarriageState,
+			person.NumBirths,
+			person.YGens,
+			person.MtGens,
+			person.MinGenealoGens,
+			person.MaxGenealoGens,
+			person.AlleleCount,
+			person.NumBlocks,
+			person.NumCentromeres,
+			person.Fitness,
+			person.NumMutations,
 		))
 	}
 	return info.String()
@@ -217,17 +264,16 @@ func deadString(ind int, pop *types.Pop, year int) string {
 // personDataString formats detailed individual data with state information
 func personDataString(ind int, pop *types.Pop, year int, state string) string {
 	var info strings.Builder
-	indInfo, exists := pop.IndData[ind]
-	if exists {
-		fields := []string{
-			"birth_year", "sex", "dad", "mom", "lifespan",
-			"lat", "lon", "marriage_state", "numbirths",
-			"Y_gens", "mt_gens", "min_genealo_gens", "max_genealo_gens",
-			"allele_count", "num_blocks", "centromeres", "fitness", "mutations",
+	if person := pop.Ind(ind); person != nil {
+		fields := []int{
+			person.BirthYear, person.Sex, person.Dad, person.Mom, person.Lifespan,
+			person.Lat, person.Lon, person.MarriageState, person.NumBirths,
+			person.YGens, person.MtGens, person.MinGenealoGens, person.MaxGenealoGens,
+			person.AlleleCount, person.NumBlocks, person.NumCentromeres, person.Fitness, person.NumMutations,
 		}
-		info.WriteString(fmt.Sprintf("%d,%d,%d,", ind, getOrDefault(indInfo, "birth_year", -1), year))
+		info.WriteString(fmt.Sprintf("%d,%d,%d,", ind, person.BirthYear, year))
 		for _, field := range fields {
-			info.WriteString(fmt.Sprintf("%d,", getOrDefault(indInfo, field, -1)))
+			info.WriteString(fmt.Sprintf("%d,", field))
 		}
 		info.WriteString(state) // Append state ('R' for removed, 'A' for alive)
 		info.WriteString("\n")
@@ -235,19 +281,11 @@ func personDataString(ind int, pop *types.Pop, year int, state string) string {
 	return info.String()
 }
 
-// getOrDefault retrieves a value from a map with a default fallback
-func getOrDefault(data map[string]int, key string, defaultVal int) int {
-	if val, ok := data[key]; ok {
-		return val
-	}
-	return defaultVal
-}
-
 // countBreedingIndividuals counts individuals of breeding age
 func countBreedingIndividuals(pop *types.Pop, year int, model *types.Model) int {
 	count := 0
-	for _, data := range pop.IndData {
-		age := year - data["birth_year"]
+	for i := range pop.IndData {
+		age := year - pop.IndData[i].BirthYear
 		if age >= int(model.Parameters["maturity"]) {
 			count++
 		}
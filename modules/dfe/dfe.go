@@ -0,0 +1,122 @@
+package dfe
+
+import (
+	"drift/types"
+	"math"
+	"math/rand/v2"
+	"sort"
+
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// The distribution of fitness effects (DFE) is chosen separately for
+// deleterious and beneficial mutations, by the deleterious_dfe and
+// beneficial_dfe parameters. Each gives the size of the effect, which is made
+// negative for deleterious mutations.
+const (
+	Deleterious = 0
+	Beneficial  = 1
+)
+
+var kinds = [2]string{"deleterious", "beneficial"}
+
+// Draw draws the size of a non-neutral mutation's effect from the DFE for its
+// kind:
+//
+//   - weibull: the Weibull distribution with the shape and scale parameters,
+//     scaled down by Weibull_adj
+//   - gamma: mean <kind>_mean and shape <kind>_shape
+//   - lognormal: mean <kind>_mean, with <kind>_shape the standard deviation
+//     of its log
+//   - exponential: mean <kind>_mean
+//   - fixed: always <kind>_mean
+//   - mixture: a mixture of the classes listed in <kind>_mixture
+//   - empirical: the effects listed in <kind>_dfe_file, in proportion to
+//     their weights
+func Draw(model *types.Model, rnd *rand.Rand, kind int) float64 {
+	dfe := model.StringParameters[kinds[kind]+"_dfe"]
+	switch dfe {
+	case "mixture":
+		classes := model.DFEMixtures[kind]
+		u := rnd.Float64()
+		for i, class := range classes {
+			if u < class.Fraction || i == len(classes)-1 {
+				return drawClass(model, rnd, kind, class.Class)
+			}
+			u -= class.Fraction
+		}
+		return 0
+	case "empirical":
+		table := model.DFETables[kind]
+		u := rnd.Float64()
+		i := sort.Search(len(table), func(i int) bool { return table[i][1] > u })
+		return table[min(i, len(table)-1)][0]
+	}
+	return drawClass(model, rnd, kind, dfe)
+}
+
+// drawClass draws from one of the distributions that make up a DFE or a
+// mixture.
+func drawClass(model *types.Model, rnd *rand.Rand, kind int, class string) float64 {
+	mean := model.Parameters[kinds[kind]+"_mean"]
+	shape := model.Parameters[kinds[kind]+"_shape"]
+	switch class {
+	case "neutral":
+		return 0
+	case "lethal":
+		return 1
+	case "nearly_neutral":
+		return distuv.Exponential{Rate: 1 / model.Parameters["nearly_neutral_mean"], Src: rnd}.Rand()
+	case "gamma":
+		return distuv.Gamma{Alpha: shape, Beta: shape / mean, Src: rnd}.Rand()
+	case "lognormal":
+		return distuv.LogNormal{Mu: math.Log(mean) - shape*shape/2, Sigma: shape, Src: rnd}.Rand()
+	case "exponential":
+		return distuv.Exponential{Rate: 1 / mean, Src: rnd}.Rand()
+	case "fixed":
+		return mean
+	}
+	return weibullRandom(rnd, model.Parameters["shape"], model.Parameters["scale"]) / model.Parameters["Weibull_adj"]
+}
+
+// Mean returns the mean size of the effects drawn from the DFE for a kind of
+// mutation.
+func Mean(model *types.Model, kind int) float64 {
+	dfe := model.StringParameters[kinds[kind]+"_dfe"]
+	switch dfe {
+	case "mixture":
+		mean := 0.0
+		for _, class := range model.DFEMixtures[kind] {
+			mean += class.Fraction * classMean(model, kind, class.Class)
+		}
+		return mean
+	case "empirical":
+		mean, previous := 0.0, 0.0
+		for _, row := range model.DFETables[kind] {
+			mean += row[0] * (row[1] - previous)
+			previous = row[1]
+		}
+		return mean
+	}
+	return classMean(model, kind, dfe)
+}
+
+func classMean(model *types.Model, kind int, class string) float64 {
+	switch class {
+	case "neutral":
+		return 0
+	case "lethal":
+		return 1
+	case "nearly_neutral":
+		return model.Parameters["nearly_neutral_mean"]
+	case "gamma", "lognormal", "exponential", "fixed":
+		return model.Parameters[kinds[kind]+"_mean"]
+	}
+	shape := model.Parameters["shape"]
+	return model.Parameters["scale"] * math.Gamma(1+1/shape) / model.Parameters["Weibull_adj"]
+}
+
+func weibullRandom(rnd *rand.Rand, shape, scale float64) float64 {
+	u := rnd.Float64()
+	return scale * math.Pow(-math.Log(u), 1/shape)
+}
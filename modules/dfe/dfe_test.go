@@ -0,0 +1,68 @@
+package dfe
+
+import (
+	"drift/types"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestMean(t *testing.T) {
+	tests := []struct {
+		name    string
+		dfe     string
+		mixture []types.MixtureClass
+		table   [][2]float64
+		want    float64
+	}{
+		{"fixed", "fixed", nil, nil, 0.02},
+		{"gamma", "gamma", nil, nil, 0.02},
+		{"weibull", "weibull", nil, nil, 0.5 * math.Gamma(2) / 10},
+		{"mixture", "mixture", []types.MixtureClass{{Class: "neutral", Fraction: 0.5}, {Class: "lethal", Fraction: 0.1},
+			{Class: "nearly_neutral", Fraction: 0.2}, {Class: "fixed", Fraction: 0.2}}, nil, 0.1 + 0.2*0.001 + 0.2*0.02},
+		{"empirical", "empirical", nil, [][2]float64{{0.1, 0.25}, {0.3, 1}}, 0.1*0.25 + 0.3*0.75},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := newModel(tt.dfe, tt.mixture, tt.table)
+			if got := Mean(model, Deleterious); math.Abs(got-tt.want) > 1e-12 {
+				t.Fatalf("Mean() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// The effects drawn from a mixture come from its classes in proportion to
+// their fractions.
+func TestDrawMixture(t *testing.T) {
+	mixture := []types.MixtureClass{{Class: "neutral", Fraction: 0.3}, {Class: "lethal", Fraction: 0.1}, {Class: "fixed", Fraction: 0.6}}
+	model := newModel("mixture", mixture, nil)
+	rnd := rand.New(rand.NewPCG(1, 2))
+	counts := make(map[float64]int)
+	const draws = 100000
+	for i := 0; i < draws; i++ {
+		counts[Draw(model, rnd, Deleterious)]++
+	}
+	for effect, want := range map[float64]float64{0: 0.3, 1: 0.1, 0.02: 0.6} {
+		if got := float64(counts[effect]) / draws; math.Abs(got-want) > 0.01 {
+			t.Errorf("effect %v drawn %v of the time, want %v", effect, got, want)
+		}
+	}
+	if len(counts) != 3 {
+		t.Errorf("drew effects %v, want only 0, 1 and 0.02", counts)
+	}
+}
+
+func newModel(dfe string, mixture []types.MixtureClass, table [][2]float64) *types.Model {
+	model := types.NewModel()
+	model.StringParameters["deleterious_dfe"] = dfe
+	model.Parameters["deleterious_mean"] = 0.02
+	model.Parameters["deleterious_shape"] = 0.3
+	model.Parameters["nearly_neutral_mean"] = 0.001
+	model.Parameters["shape"] = 1
+	model.Parameters["scale"] = 0.5
+	model.Parameters["Weibull_adj"] = 10
+	model.DFEMixtures[Deleterious] = mixture
+	model.DFETables[Deleterious] = table
+	return model
+}
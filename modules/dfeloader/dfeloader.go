@@ -0,0 +1,79 @@
+package dfeloader
+
+import (
+	"drift/modules/csvutils"
+	"drift/modules/paramloader"
+	"drift/types"
+	"fmt"
+)
+
+// Load the tables of mutation effects named by the deleterious_dfe_file and
+// beneficial_dfe_file parameters, for the empirical DFEs that use them, into
+// the model's DFETables. Each row is Effect,Weight, where the effect is its
+// size (a positive number, whichever the sign of the mutation) and the weight
+// is how often it occurs, relative to the other rows. The classes of a
+// mixture DFE are parsed into DFEMixtures at the same time, so that they are
+// not parsed again for every mutation.
+func LoadDFETables(model *types.Model, configRoot string) error {
+	for kind, name := range [2]string{"deleterious", "beneficial"} {
+		if model.StringParameters[name+"_dfe"] == "mixture" {
+			classes, err := paramloader.Mixture(model, name)
+			if err != nil {
+				return fmt.Errorf("Error in %s_mixture: %v", name, err)
+			}
+			model.DFEMixtures[kind] = classes
+		}
+
+		fileName := model.StringParameters[name+"_dfe_file"]
+		if fileName == "" || model.StringParameters[name+"_dfe"] != "empirical" {
+			continue
+		}
+
+		// Load the CSV file
+		csvLoader := csvutils.CSVLoader{
+			FileName:   fileName,
+			Dir:        configRoot,
+			MinRecords: 2,
+		}
+		records, err := csvLoader.LoadCSV()
+		if err != nil {
+			return err
+		}
+
+		// Skip the header row and process each record
+		var table [][2]float64
+		var total float64
+		for _, record := range records[1:] {
+			// Ensure the record has at least 2 fields
+			err := csvLoader.CheckRecord(record, 2)
+			if err != nil {
+				return err
+			}
+
+			effect, err := csvLoader.ParseFloat64(record, 0)
+			if err != nil {
+				return err
+			}
+			if effect < 0 {
+				return csvutils.ErrInvalidField{CSVLoader: csvLoader, Record: record, Field: 0, Message: "The effect should be the size of the effect, not negative"}
+			}
+
+			weight, err := csvLoader.ParseFloat64(record, 1)
+			if err != nil {
+				return err
+			}
+			if weight <= 0 {
+				return csvutils.ErrInvalidField{CSVLoader: csvLoader, Record: record, Field: 1, Message: "The weight should be greater than 0"}
+			}
+			total += weight
+			table = append(table, [2]float64{effect, total})
+		}
+
+		// turn the running totals into cumulative probabilities
+		for i := range table {
+			table[i][1] /= total
+		}
+		model.DFETables[kind] = table
+	}
+	return nil
+}
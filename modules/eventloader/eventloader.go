@@ -0,0 +1,118 @@
+package eventloader
+
+import (
+	"drift/modules/csvutils"
+	"drift/modules/paramloader"
+	"drift/types"
+	"fmt"
+	"slices"
+)
+
+// Parameters that the lifecycle stages read afresh each year, and so can be
+// changed by an event part way through a run. Parameters such as multiplier
+// or track_DNA shape the data built at load and must stay fixed.
+var changeable = []string{"mu", "birth_prob", "max_growth_rate", "menopause", "lifespan_drop",
+	"max_pop_size", "max_breeding_inds", "selfing_rate"}
+
+// Load the events file named by the events_file parameter, if any, and
+// populate the model's Events list. Each row is year,event,value,until, where
+// the event is one of:
+//
+//	bottleneck      cap the population at value until the year in until
+//	mass_mortality  each individual dies with probability value
+//	seed            introduce the seed, instead of in seed_year
+//	<parameter>     change one of the changeable parameters, such as mu or max_pop_size, to value
+func LoadEvents(model *types.Model, configRoot string) error {
+	fileName := model.StringParameters["events_file"]
+	if fileName == "" {
+		return nil
+	}
+
+	// Load the CSV file
+	csvLoader := csvutils.CSVLoader{
+		FileName:   fileName,
+		Dir:        configRoot,
+		MinRecords: 2,
+	}
+	records, err := csvLoader.LoadCSV()
+	if err != nil {
+		return err
+	}
+
+	// Skip the header row and process each record
+	seeds := 0
+	for _, record := range records[1:] {
+		// Ensure the record has at least 3 fields
+		err := csvLoader.CheckRecord(record, 3)
+		if err != nil {
+			return err
+		}
+		invalid := func(field int, message string) error {
+			return csvutils.ErrInvalidField{CSVLoader: csvLoader, Record: record, Field: field, Message: message}
+		}
+
+		year, err := csvLoader.Atoi(record, 0)
+		if err != nil {
+			return err
+		}
+		if year < 0 {
+			return invalid(0, "The year cannot be negative")
+		}
+		event := types.Event{Year: year, Action: record[1]}
+
+		switch event.Action {
+		case "bottleneck":
+			event.Value, err = csvLoader.ParseFloat64(record, 2)
+			if err != nil {
+				return err
+			}
+			if event.Value < 0 {
+				return invalid(2, "The bottleneck size cannot be negative")
+			}
+			if len(record) < 4 {
+				return invalid(1, "A bottleneck needs the last year in the until column")
+			}
+			event.Until, err = csvLoader.Atoi(record, 3)
+			if err != nil {
+				return err
+			}
+			if event.Until < year {
+				return invalid(3, "The bottleneck ends before it starts")
+			}
+		case "mass_mortality":
+			event.Value, err = csvLoader.ParseFloat64(record, 2)
+			if err != nil {
+				return err
+			}
+			if event.Value < 0 || event.Value > 1 {
+				return invalid(2, "The fraction that dies should be between 0 and 1")
+			}
+		case "seed":
+			seeds++
+			if seeds > 1 {
+				return invalid(1, "Only one seed can be introduced")
+			}
+		default:
+			if !slices.Contains(changeable, event.Action) {
+				return invalid(1, fmt.Sprintf("%s is not an event or a parameter that can change during a run, only %v",
+					event.Action, changeable))
+			}
+			event.Value, err = paramloader.ParseNumeric(event.Action, record[2])
+			if err != nil {
+				return invalid(1, err.Error())
+			}
+		}
+		model.Events = append(model.Events, event)
+	}
+
+	// Events in the same year keep the order of the file
+	slices.SortStableFunc(model.Events, func(a, b types.Event) int {
+		return a.Year - b.Year
+	})
+
+	// The seed event takes the place of seed_year
+	if seeds > 0 {
+		model.Parameters["seed_year"] = model.Parameters["end_year"] + 1
+	}
+	return nil
+}
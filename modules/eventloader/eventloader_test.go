@@ -0,0 +1,47 @@
+package eventloader
+
+import (
+	"drift/types"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadEvents(t *testing.T) {
+	tests := []struct {
+		name    string
+		row     string
+		wantErr bool
+	}{
+		{"bottleneck", "50,bottleneck,40,52", false},
+		{"bottleneck without an end", "50,bottleneck,40,", true},
+		{"bottleneck that ends before it starts", "50,bottleneck,40,49", true},
+		{"mass mortality", "50,mass_mortality,0.3,", false},
+		{"mass mortality above 1", "50,mass_mortality,1.3,", true},
+		{"seed", "50,seed,,", false},
+		{"changeable parameter", "50,max_pop_size,2000,", false},
+		{"changeable parameter out of range", "50,mu,-1,", true},
+		{"parameter fixed at load", "50,multiplier,2,", true},
+		{"track_DNA fixed at load", "50,track_DNA,1,", true},
+		{"unknown event", "50,meteor,1,", true},
+		{"negative year", "-1,mu,1,", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			err := os.WriteFile(filepath.Join(dir, "events.csv"), []byte("year,event,value,until\n"+tt.row+"\n"), 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			model := types.NewModel()
+			model.StringParameters["events_file"] = "events.csv"
+			err = LoadEvents(model, dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadEvents(%q) error = %v, want error %v", tt.row, err, tt.wantErr)
+			}
+			if err == nil && len(model.Events) != 1 {
+				t.Fatalf("LoadEvents(%q) loaded %d events, want 1", tt.row, len(model.Events))
+			}
+		})
+	}
+}
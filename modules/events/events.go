@@ -0,0 +1,54 @@
+package events
+
+import (
+	"drift/modules/death"
+	"drift/modules/rng"
+	"drift/types"
+	"fmt"
+	"slices"
+)
+
+// Apply carries out every event scheduled for the given year, in the order
+// they appear in the events file. It is called at the start of each year,
+// before any of the lifecycle stages.
+func Apply(model *types.Model, pop *types.Pop, year int) {
+	for _, event := range model.Events {
+		if event.Year != year {
+			continue
+		}
+		switch event.Action {
+		case "bottleneck":
+			model.Parameters["bottleneck_start"] = float64(event.Year)
+			model.Parameters["bottleneck_end"] = float64(event.Until)
+			model.Parameters["bottleneck_size"] = event.Value
+			fmt.Printf("   Event: bottleneck of %v until year %d\n", event.Value, event.Until)
+		case "mass_mortality":
+			deaths := massMortality(model, pop, event.Value)
+			fmt.Printf("   Event: mass mortality, %d died\n", deaths)
+		case "seed":
+			model.Parameters["seed_year"] = float64(year)
+			fmt.Println("   Event: seed introduction")
+		default: // one of the parameters LoadEvents allows to change
+			model.Parameters[event.Action] = event.Value
+			fmt.Printf("   Event: %s set to %v\n", event.Action, event.Value)
+		}
+	}
+}
+
+// massMortality kills each individual, other than the seed, with the given
+// probability and returns the number that died.
+func massMortality(model *types.Model, pop *types.Pop, fraction float64) int {
+	rnd := model.RNG[rng.Events]
+	deaths := 0
+	for _, ind := range pop.IDs() {
+		if slices.Contains(pop.Seeds, ind) { // Don't kill off the seeds
+			continue
+		}
+		if rnd.Float64() < fraction {
+			death.RIP(ind, pop, model)
+			deaths++
+			pop.Tracking["cull_deaths"]++
+		}
+	}
+	return deaths
+}
@@ -0,0 +1,58 @@
+package fitnesscurveloader
+
+import (
+	"drift/modules/csvutils"
+	"drift/types"
+)
+
+// Load the fitness curve named by the fitness_curve_file parameter, if any,
+// into the model's FitnessCurve. Each row is Load,Fitness, where the load is
+// the combined effect of an individual's mutations with the sign reversed, so
+// that deleterious mutations add to it. The loads must increase and the
+// fitness may not be negative.
+func LoadFitnessCurve(model *types.Model, configRoot string) error {
+	fileName := model.StringParameters["fitness_curve_file"]
+	if fileName == "" {
+		return nil
+	}
+
+	// Load the CSV file
+	csvLoader := csvutils.CSVLoader{
+		FileName:   fileName,
+		Dir:        configRoot,
+		MinRecords: 2,
+	}
+	records, err := csvLoader.LoadCSV()
+	if err != nil {
+		return err
+	}
+
+	// Skip the header row and process each record
+	var curve [][2]float64
+	for _, record := range records[1:] {
+		// Ensure the record has at least 2 fields
+		err := csvLoader.CheckRecord(record, 2)
+		if err != nil {
+			return err
+		}
+
+		load, err := csvLoader.ParseFloat64(record, 0)
+		if err != nil {
+			return err
+		}
+		if n := len(curve); n > 0 && load <= curve[n-1][0] {
+			return csvutils.ErrInvalidField{CSVLoader: csvLoader, Record: record, Field: 0, Message: "The loads should increase"}
+		}
+
+		fitness, err := csvLoader.ParseFloat64(record, 1)
+		if err != nil {
+			return err
+		}
+		if fitness < 0 {
+			return csvutils.ErrInvalidField{CSVLoader: csvLoader, Record: record, Field: 1, Message: "The fitness should not be negative"}
+		}
+		curve = append(curve, [2]float64{load, fitness})
+	}
+	model.FitnessCurve = curve
+	return nil
+}
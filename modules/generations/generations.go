@@ -0,0 +1,180 @@
+package generations
+
+import (
+	"drift/modules/birth"
+	"drift/modules/death"
+	"drift/modules/rng"
+	"drift/modules/seedpopulation"
+	"drift/modules/selection"
+	"drift/modules/stage"
+	"drift/types"
+	"math"
+
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Name is the engine and scenario module name of discrete generations.
+const Name = "generations"
+
+// The generations engine registers a module for each of the yearly stages, so
+// that engine=generations, or a scenario naming them, runs the model in
+// discrete generations, with each step of the main loop being one generation
+// rather than one year. Step does the work of the birth, marriage and death
+// stages, so the marriage and death modules do nothing.
+func init() {
+	stage.Register(stage.Seed, Name, stage.StageFunc(
+		func(model *types.Model, pop *types.Pop, generation int) error {
+			// Everyone alive at the start of a generation is one of its
+			// parents, whatever the maturity parameter says
+			return seedpopulation.SeedThePopulation(model, pop, generation, 0)
+		}))
+	stage.Register(stage.Birth, Name, Step)
+	stage.Register(stage.Marriage, Name, nothing)
+	stage.Register(stage.Death, Name, nothing)
+}
+
+// Step replaces the year's birth, marriage and death stages with one
+// generation.
+var Step = stage.StageFunc(func(model *types.Model, pop *types.Pop, generation int) error {
+	return Generation(model, pop, generation)
+})
+
+var nothing = stage.StageFunc(func(model *types.Model, pop *types.Pop, generation int) error {
+	return nil
+})
+
+// Generation replaces the whole population with their offspring, in the
+// manner of Mendel's Accountant. Every adult is paired at random with one of
+// the opposite sex (or, for hermaphrodites, every adult bears seed), each
+// couple has a number of children drawn from the fertility distribution, the
+// parents are removed, and then selection and the carrying capacity decide
+// which of the children survive to become the next generation's adults.
+func Generation(model *types.Model, pop *types.Pop, generation int) error {
+	parents := pop.IDs()
+	var err error
+	if birth.Hermaphrodite(model) {
+		err = selfOrOutcross(model, pop, parents, generation)
+	} else {
+		err = pairAndBreed(model, pop, parents, generation)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The parents' generation is replaced by their children
+	for _, ind := range parents {
+		death.RIP(ind, pop, model)
+		pop.Tracking["deaths"]++
+	}
+
+	strategy := selection.For(model)
+	rnd := model.RNG[rng.Death]
+
+	// Viability selection, which stands in for annual selection: each child
+	// survives with a probability equal to its fitness
+	if strategy.Annual || strategy.Viability {
+		for _, ind := range pop.IDs() {
+			if rnd.Float64() >= selection.Fitness(model, pop.Ind(ind)) {
+				death.RIP(ind, pop, model)
+				pop.Tracking["cull_deaths"]++
+			}
+		}
+	}
+
+	// The survivors are culled down to the carrying capacity, or the
+	// bottleneck size if that is smaller: at random, or with truncation or
+	// probability selection, the lowest ranked first
+	maxPopSize := int(model.Parameters["max_pop_size"])
+	if int(model.Parameters["bottleneck_start"]) <= generation && int(model.Parameters["bottleneck_end"]) >= generation {
+		maxPopSize = min(maxPopSize, int(model.Parameters["bottleneck_size"]))
+	}
+	if excess := len(pop.IndData) - maxPopSize; excess > 0 {
+		children := pop.IDs()
+		if strategy.Cull == selection.Random {
+			rnd.Shuffle(len(children), func(i, j int) { children[i], children[j] = children[j], children[i] })
+		} else {
+			children = strategy.Rank(model, pop, rnd.Rand, children)
+		}
+		for _, ind := range children[:excess] {
+			death.RIP(ind, pop, model)
+			pop.Tracking["cull_deaths"]++
+		}
+	}
+	return nil
+}
+
+// pairAndBreed pairs every adult with a random partner of the opposite sex,
+// and each couple has children.
+func pairAndBreed(model *types.Model, pop *types.Pop, parents []int, generation int) error {
+	var men, women []int
+	for _, ind := range parents {
+		person := pop.Ind(ind)
+		person.MarriageState = -1
+		if person.Sex == 0 {
+			men = append(men, ind)
+		} else {
+			women = append(women, ind)
+		}
+	}
+	rnd := model.RNG[rng.Marriage]
+	rnd.Shuffle(len(men), func(i, j int) { men[i], men[j] = men[j], men[i] })
+	rnd.Shuffle(len(women), func(i, j int) { women[i], women[j] = women[j], women[i] })
+	numCouples := min(len(men), len(women))
+	for i := 0; i < numCouples; i++ {
+		pop.Ind(men[i]).MarriageState = women[i]
+		pop.Ind(women[i]).MarriageState = men[i]
+		pop.Tracking["marriages"]++
+	}
+
+	var couples [][2]int
+	for i := 0; i < numCouples; i++ {
+		for n := numOffspring(model); n > 0; n-- {
+			if born(model, pop, men[i], women[i]) {
+				couples = append(couples, [2]int{men[i], women[i]})
+			}
+		}
+	}
+	return birth.Offspring(model, pop, couples, generation)
+}
+
+// selfOrOutcross lets every hermaphrodite bear seed, each seed either selfed
+// or fertilized by another plant.
+func selfOrOutcross(model *types.Model, pop *types.Pop, parents []int, generation int) error {
+	rnd := model.RNG[rng.Birth]
+	var couples [][2]int
+	for _, ind := range parents {
+		for n := numOffspring(model); n > 0; n-- {
+			dad := birth.Partner(model, rnd.Rand, ind, parents)
+			if born(model, pop, dad, ind) {
+				couples = append(couples, [2]int{dad, ind})
+			}
+		}
+	}
+	return birth.Offspring(model, pop, couples, generation)
+}
+
+// born reports whether a couple's child is born, which with birth selection
+// is a chance equal to their average fitness.
+func born(model *types.Model, pop *types.Pop, dad int, mom int) bool {
+	strategy := selection.For(model)
+	if !strategy.Birth {
+		return true
+	}
+	return model.RNG[rng.Birth].Float64() < strategy.BirthChance(model, pop.Ind(dad), pop.Ind(mom))
+}
+
+// numOffspring draws the number of children for one couple. With a fixed
+// distribution, a fractional mean is met on average by rounding up at random.
+func numOffspring(model *types.Model) int {
+	rnd := model.RNG[rng.Birth]
+	mean := model.Parameters["offspring_per_female"]
+	if model.StringParameters["fertility_distribution"] == "poisson" {
+		poisson := distuv.Poisson{Lambda: mean, Src: rnd.Source}
+		return int(poisson.Rand())
+	}
+	n := int(math.Floor(mean))
+	if rnd.Float64() < mean-float64(n) {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,223 @@
+package genome
+
+import (
+	"math/bits"
+)
+
+// Haplotype is one copy of the digital genome, with one bit per recombination
+// block. Bit i is stored in word i/64 at position i%64, so lower positions in
+// the genome are lower bits in each word. Bits beyond the end of the genome,
+// in the last word, are always kept clear.
+type Haplotype []uint64
+
+// Words returns the number of uint64s needed to hold numbits bits.
+func Words(numbits int) int {
+	return (numbits + 63) / 64
+}
+
+// New returns an empty haplotype numbits long.
+func New(numbits int) Haplotype {
+	return make(Haplotype, Words(numbits))
+}
+
+// Get reports whether bit i is set.
+func (h Haplotype) Get(i int) bool {
+	return h[i/64]&(1<<(i%64)) != 0
+}
+
+// Set sets bit i.
+func (h Haplotype) Set(i int) {
+	h[i/64] |= 1 << (i % 64)
+}
+
+// Clear clears bit i.
+func (h Haplotype) Clear(i int) {
+	h[i/64] &^= 1 << (i % 64)
+}
+
+// wordMask returns the bits of word w that lie within [start, end).
+func wordMask(w int, start int, end int) uint64 {
+	lo, hi := w*64, w*64+64
+	if start > lo {
+		lo = start
+	}
+	if end < hi {
+		hi = end
+	}
+	if lo >= hi {
+		return 0
+	}
+	n := uint(hi - lo)
+	if n == 64 {
+		return ^uint64(0)
+	}
+	return ((1 << n) - 1) << uint(lo-w*64)
+}
+
+// SetRange sets bits start to end-1.
+func (h Haplotype) SetRange(start int, end int) {
+	if start >= end {
+		return
+	}
+	for w := start / 64; w <= (end-1)/64; w++ {
+		h[w] |= wordMask(w, start, end)
+	}
+}
+
+// ClearRange clears bits start to end-1.
+func (h Haplotype) ClearRange(start int, end int) {
+	if start >= end {
+		return
+	}
+	for w := start / 64; w <= (end-1)/64; w++ {
+		h[w] &^= wordMask(w, start, end)
+	}
+}
+
+// FlipRange inverts bits start to end-1.
+func (h Haplotype) FlipRange(start int, end int) {
+	if start >= end {
+		return
+	}
+	for w := start / 64; w <= (end-1)/64; w++ {
+		h[w] ^= wordMask(w, start, end)
+	}
+}
+
+// RangeMask returns a haplotype numbits long with only bits start to end-1 set.
+func RangeMask(numbits int, start int, end int) Haplotype {
+	h := New(numbits)
+	h.SetRange(start, end)
+	return h
+}
+
+// Count returns the number of set bits.
+func (h Haplotype) Count() int {
+	count := 0
+	for _, word := range h {
+		count += bits.OnesCount64(word)
+	}
+	return count
+}
+
+// CountRange returns the number of set bits from start to end-1.
+func (h Haplotype) CountRange(start int, end int) int {
+	if start >= end {
+		return 0
+	}
+	count := 0
+	for w := start / 64; w <= (end-1)/64; w++ {
+		count += bits.OnesCount64(h[w] & wordMask(w, start, end))
+	}
+	return count
+}
+
+// Recombine returns the haplotype made by taking a where the mask is set and b
+// where it is clear:
+//
+//	child = (mask & a) | (^mask & b)
+func Recombine(mask Haplotype, a Haplotype, b Haplotype) Haplotype {
+	child := make(Haplotype, len(mask))
+	for i := range mask {
+		child[i] = (mask[i] & a[i]) | (^mask[i] & b[i])
+	}
+	return child
+}
+
+// Or returns a | b.
+func Or(a Haplotype, b Haplotype) Haplotype {
+	result := make(Haplotype, len(a))
+	for i := range a {
+		result[i] = a[i] | b[i]
+	}
+	return result
+}
+
+// And returns a & b.
+func And(a Haplotype, b Haplotype) Haplotype {
+	result := make(Haplotype, len(a))
+	for i := range a {
+		result[i] = a[i] & b[i]
+	}
+	return result
+}
+
+// Xor returns a ^ b.
+func Xor(a Haplotype, b Haplotype) Haplotype {
+	result := make(Haplotype, len(a))
+	for i := range a {
+		result[i] = a[i] ^ b[i]
+	}
+	return result
+}
+
+// Nor returns ^(a | b). The bits past the end of the genome are set in the
+// result, so count it with CountRange.
+func Nor(a Haplotype, b Haplotype) Haplotype {
+	result := make(Haplotype, len(a))
+	for i := range a {
+		result[i] = ^(a[i] | b[i])
+	}
+	return result
+}
+
+// Blocks returns the number of runs of contiguous set bits between start and
+// end-1. A run starts at every set bit whose lower neighbour is clear, so the
+// runs can be counted a word at a time without visiting each bit.
+func (h Haplotype) Blocks(start int, end int) int {
+	if start >= end {
+		return 0
+	}
+	blocks := 0
+	var carry uint64 // top bit of the previous word, within the range
+	for w := start / 64; w <= (end-1)/64; w++ {
+		word := h[w] & wordMask(w, start, end)
+		blocks += bits.OnesCount64(word &^ (word<<1 | carry))
+		carry = word >> 63
+	}
+	return blocks
+}
+
+// Runs calls fn with the start and length of each run of contiguous set bits
+// between start and end-1, in order. A run that crosses start or end is cut
+// off there.
+func (h Haplotype) Runs(start int, end int, fn func(start int, length int)) {
+	pos := start
+	for pos < end {
+		runStart := h.nextSet(pos, end)
+		if runStart >= end {
+			return
+		}
+		runEnd := h.nextClear(runStart, end)
+		fn(runStart, runEnd-runStart)
+		pos = runEnd
+	}
+}
+
+// nextSet returns the position of the first set bit at or after pos, or end if
+// there is none before end.
+func (h Haplotype) nextSet(pos int, end int) int {
+	for pos < end {
+		w := pos / 64
+		word := (h[w] & wordMask(w, pos, end)) >> uint(pos%64)
+		if word != 0 {
+			return pos + bits.TrailingZeros64(word)
+		}
+		pos = (w + 1) * 64
+	}
+	return end
+}
+
+// nextClear returns the position of the first clear bit at or after pos, or
+// end if there is none before end.
+func (h Haplotype) nextClear(pos int, end int) int {
+	for pos < end {
+		w := pos / 64
+		word := (^h[w] & wordMask(w, pos, end)) >> uint(pos%64)
+		if word != 0 {
+			return pos + bits.TrailingZeros64(word)
+		}
+		pos = (w + 1) * 64
+	}
+	return end
+}
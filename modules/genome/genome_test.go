@@ -0,0 +1,175 @@
+package genome
+
+import (
+	"slices"
+	"testing"
+)
+
+// bitsOf lists the set bits of a haplotype, to compare against.
+func bitsOf(h Haplotype, numbits int) []int {
+	var set []int
+	for i := 0; i < numbits; i++ {
+		if h.Get(i) {
+			set = append(set, i)
+		}
+	}
+	return set
+}
+
+func TestSingleBits(t *testing.T) {
+	tests := []struct {
+		name string
+		bit  int
+	}{
+		{"first bit", 0},
+		{"last bit of word 0", 63},
+		{"first bit of word 1", 64},
+		{"last bit of word 1", 127},
+		{"first bit of word 2", 128},
+		{"last bit", 149},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(150)
+			h.Set(tt.bit)
+			if got := bitsOf(h, 150); !slices.Equal(got, []int{tt.bit}) {
+				t.Fatalf("after Set(%d), set bits are %v", tt.bit, got)
+			}
+			if h.Count() != 1 {
+				t.Fatalf("Count() = %d, want 1", h.Count())
+			}
+			h.Clear(tt.bit)
+			if h.Get(tt.bit) || h.Count() != 0 {
+				t.Fatalf("after Clear(%d), set bits are %v", tt.bit, bitsOf(h, 150))
+			}
+		})
+	}
+}
+
+func TestRanges(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end int
+	}{
+		{"empty", 10, 10},
+		{"within a word", 3, 17},
+		{"up to a word boundary", 60, 64},
+		{"from a word boundary", 64, 70},
+		{"across one boundary", 62, 66},
+		{"a whole word", 64, 128},
+		{"across two boundaries", 1, 140},
+		{"to the end", 127, 150},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want []int
+			for i := tt.start; i < tt.end; i++ {
+				want = append(want, i)
+			}
+
+			h := New(150)
+			h.SetRange(tt.start, tt.end)
+			if got := bitsOf(h, 150); !slices.Equal(got, want) {
+				t.Fatalf("SetRange set %v, want %v", got, want)
+			}
+			if got := h.CountRange(0, 150); got != len(want) {
+				t.Fatalf("CountRange(0, 150) = %d, want %d", got, len(want))
+			}
+			if got := h.CountRange(tt.start+1, tt.end); tt.end > tt.start && got != len(want)-1 {
+				t.Fatalf("CountRange from the second bit = %d, want %d", got, len(want)-1)
+			}
+
+			h.ClearRange(tt.start, tt.end)
+			if h.Count() != 0 {
+				t.Fatalf("ClearRange left %v", bitsOf(h, 150))
+			}
+
+			if !slices.Equal(RangeMask(150, tt.start, tt.end), func() Haplotype {
+				m := New(150)
+				m.SetRange(tt.start, tt.end)
+				return m
+			}()) {
+				t.Fatal("RangeMask differs from SetRange")
+			}
+		})
+	}
+}
+
+func TestFlipRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end int
+	}{
+		{"within a word", 5, 9},
+		{"across a boundary", 60, 70},
+		{"a whole word", 64, 128},
+		{"to the end", 100, 150},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// every other bit set, so flipping must both set and clear
+			h := New(150)
+			for i := 0; i < 150; i += 2 {
+				h.Set(i)
+			}
+			h.FlipRange(tt.start, tt.end)
+			for i := 0; i < 150; i++ {
+				want := i%2 == 0
+				if i >= tt.start && i < tt.end {
+					want = !want
+				}
+				if h.Get(i) != want {
+					t.Fatalf("bit %d is %v, want %v", i, h.Get(i), want)
+				}
+			}
+			h.FlipRange(tt.start, tt.end)
+			if h.Count() != 75 {
+				t.Fatalf("flipping twice left %d bits set, want 75", h.Count())
+			}
+		})
+	}
+}
+
+func TestBlocksAndRuns(t *testing.T) {
+	tests := []struct {
+		name       string
+		set        [][2]int // ranges to set
+		start, end int
+		runs       [][2]int // start and length of each run
+	}{
+		{"none", nil, 0, 150, nil},
+		{"one run", [][2]int{{10, 20}}, 0, 150, [][2]int{{10, 10}}},
+		{"run across a boundary", [][2]int{{60, 70}}, 0, 150, [][2]int{{60, 10}}},
+		{"runs either side of a boundary", [][2]int{{60, 64}, {65, 70}}, 0, 150, [][2]int{{60, 4}, {65, 5}}},
+		{"run cut off by the range", [][2]int{{60, 70}}, 64, 66, [][2]int{{64, 2}}},
+		{"run touching the end", [][2]int{{140, 150}}, 0, 150, [][2]int{{140, 10}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(150)
+			for _, r := range tt.set {
+				h.SetRange(r[0], r[1])
+			}
+			if got := h.Blocks(tt.start, tt.end); got != len(tt.runs) {
+				t.Fatalf("Blocks = %d, want %d", got, len(tt.runs))
+			}
+			var runs [][2]int
+			h.Runs(tt.start, tt.end, func(start int, length int) {
+				runs = append(runs, [2]int{start, length})
+			})
+			if !slices.Equal(runs, tt.runs) {
+				t.Fatalf("Runs = %v, want %v", runs, tt.runs)
+			}
+		})
+	}
+}
+
+func TestRecombine(t *testing.T) {
+	a, b, mask := New(130), New(130), New(130)
+	a.SetRange(0, 130)
+	mask.SetRange(60, 70)
+	child := Recombine(mask, a, b)
+	if got := bitsOf(child, 130); len(got) != 10 || got[0] != 60 || got[9] != 69 {
+		t.Fatalf("Recombine took %v from a, want 60 to 69", got)
+	}
+}
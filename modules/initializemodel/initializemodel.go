@@ -3,35 +3,61 @@ package initializemodel
 import (
 	"drift/modules/actuarialloader"
 	"drift/modules/chromosomeloader"
+	"drift/modules/dfeloader"
+	"drift/modules/eventloader"
+	"drift/modules/fitnesscurveloader"
 	"drift/modules/maploader"
 	"drift/modules/paramloader"
-	"drift/modules/save"
+	"drift/modules/recmaploader"
+	"drift/modules/scheduleloader"
 	"drift/types"
 	"fmt"
 	"math"
 )
 
-// Initializes the model based on the configuration files.
-func InitializeModel(configRoot string, mapRoot string) (*types.Model, error) {
-	model := &types.Model{
-		Parameters:     make(map[string]float64),
-		PlotFlags:      make(map[string]bool),
-		ChromosomeArms: make(map[int]map[int][]int),
-		DeathRisk:      make(map[int]float64),
-		CumulativeProb: make(map[int]float64),
-		FreeParameters: make(map[string]int),
-		Map:            make(map[int]map[int]int),
-	}
+// Initializes the model based on the configuration files. If paramsFile is
+// not empty its parameters are layered on top of the defaults, and then each
+// override (in name=value form) is applied in turn.
+func InitializeModel(configRoot string, mapRoot string, paramsFile string, overrides []string) (*types.Model, error) {
+	model := types.NewModel()
 
 	// Attempt to load each config file. Failure will be fatal.
 	err := paramloader.LoadParameters(model, configRoot)
 	if err != nil {
 		return nil, err
 	}
+	if paramsFile != "" {
+		err = paramloader.LoadParameterFile(model, paramsFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, override := range overrides {
+		err = paramloader.Override(model, override)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = paramloader.Validate(model)
+	if err != nil {
+		return nil, err
+	}
 	err = chromosomeloader.LoadChromosomeArms(model, configRoot)
 	if err != nil {
 		return nil, err
 	}
+	err = recmaploader.LoadRecombinationMap(model, configRoot)
+	if err != nil {
+		return nil, err
+	}
+	err = fitnesscurveloader.LoadFitnessCurve(model, configRoot)
+	if err != nil {
+		return nil, err
+	}
+	err = dfeloader.LoadDFETables(model, configRoot)
+	if err != nil {
+		return nil, err
+	}
 	err = actuarialloader.LoadActuarialTable(model, configRoot)
 	if err != nil {
 		return nil, err
@@ -40,13 +66,18 @@ func InitializeModel(configRoot string, mapRoot string) (*types.Model, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = eventloader.LoadEvents(model, configRoot)
+	if err != nil {
+		return nil, err
+	}
+	err = scheduleloader.LoadSchedules(model, configRoot)
+	if err != nil {
+		return nil, err
+	}
 
 	// Calculate derived values
 	model.Parameters["mu_sig_figs"] = math.Pow(1, model.Parameters["mu_sig_figs"])
 
-	// Prepare output files
-	save.SaveHeaders(model.ModelName)
-
 	// Initialize free parameters
 	model.FreeParameters["indID"] = 0         // Starting ID for individuals
 	model.FreeParameters["seed"] = -1         // No seed initially
@@ -1,34 +1,54 @@
 package marriage
 
 import (
+	"drift/modules/rng"
+	"drift/modules/stage"
 	"drift/types"
-	"math/rand"
-	"time"
+	"slices"
 )
 
+func init() {
+	stage.Register(stage.Marriage, stage.Default, stage.StageFunc(
+		func(model *types.Model, pop *types.Pop, year int) error {
+			Marriage(model, pop, year)
+			return nil
+		}))
+}
+
 func Marriage(model *types.Model, pop *types.Pop, year int) {
+	// Hermaphrodites choose a partner for each seed at birth instead
+	if model.StringParameters["mating_system"] == "hermaphrodite" {
+		return
+	}
+
 	var availableMen, availableWomen []int
 
 	// Find eligible individuals
-	for id, data := range pop.IndData {
-		if data["marriage_state"] == -1 && year-data["birth_year"] >= int(model.Parameters["maturity"]) {
-			if data["sex"] == 0 {
-				availableMen = append(availableMen, id)
-			} else if data["sex"] == 1 {
-				availableWomen = append(availableWomen, id)
+	for i := range pop.IndData {
+		ind := &pop.IndData[i]
+		if ind.MarriageState == -1 && year-ind.BirthYear >= int(model.Parameters["maturity"]) {
+			if ind.Sex == 0 {
+				availableMen = append(availableMen, ind.ID)
+			} else if ind.Sex == 1 {
+				availableWomen = append(availableWomen, ind.ID)
 			}
 		}
 	}
 
 	// Randomize people (note this will create unusual age gaps among married couples, fix?)
-	rand.Seed(time.Now().UnixNano())
-	rand.Shuffle(len(availableMen), func(i, j int) {
+	rnd := model.RNG[rng.Marriage]
+	rnd.Shuffle(len(availableMen), func(i, j int) {
 		availableMen[i], availableMen[j] = availableMen[j], availableMen[i]
 	})
-	rand.Shuffle(len(availableWomen), func(i, j int) {
+	rnd.Shuffle(len(availableWomen), func(i, j int) {
 		availableWomen[i], availableWomen[j] = availableWomen[j], availableWomen[i]
 	})
 
+	// Unless mating is fully random, keep only the couples who live close enough
+	if maxDistance := model.ParamAt("random_mating", year); maxDistance < 1 {
+		availableMen, availableWomen = pairNearby(pop, availableMen, availableWomen, maxDistance)
+	}
+
 	// Trim male and female lists to the shortest of the two
 	if len(availableMen) > len(availableWomen) {
 		availableMen = availableMen[:len(availableWomen)]
@@ -38,10 +58,36 @@ func Marriage(model *types.Model, pop *types.Pop, year int) {
 
 	// Assign spouses
 	for i := 0; i < len(availableMen); i++ {
-		pop.IndData[availableMen[i]]["marriage_state"] = availableWomen[i]
-		pop.IndData[availableWomen[i]]["marriage_state"] = availableMen[i]
+		pop.Ind(availableMen[i]).MarriageState = availableWomen[i]
+		pop.Ind(availableWomen[i]).MarriageState = availableMen[i]
 		pop.Tracking["marriages"]++
 		// Uncomment for debugging
 		// fmt.Printf(" M: %d : %d\n", availableMen[i], availableWomen[i])
 	}
 }
+
+// Lat and lon are whole numbers, with locationScale of them to one unit of
+// distance, so the founders lie within 0.5 units of the origin.
+const locationScale = 1000
+
+// pairNearby pairs each man, in order, with the first woman left in the list
+// who lives no more than maxDistance units away. It returns the men and women
+// who were paired, with each man at the same index as his wife.
+func pairNearby(pop *types.Pop, men []int, women []int, maxDistance float64) ([]int, []int) {
+	limit := maxDistance * locationScale
+	var husbands, wives []int
+	for _, man := range men {
+		m := pop.Ind(man)
+		for i, woman := range women {
+			w := pop.Ind(woman)
+			dLat, dLon := float64(m.Lat-w.Lat), float64(m.Lon-w.Lon)
+			if dLat*dLat+dLon*dLon <= limit*limit {
+				husbands = append(husbands, man)
+				wives = append(wives, woman)
+				women = slices.Delete(women, i, i+1)
+				break
+			}
+		}
+	}
+	return husbands, wives
+}
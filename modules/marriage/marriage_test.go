@@ -0,0 +1,47 @@
+package marriage
+
+import (
+	"drift/modules/rng"
+	"drift/types"
+	"testing"
+)
+
+func TestMarriageScheduledDistance(t *testing.T) {
+	const man, near, far = 1, 2, 3
+	tests := []struct {
+		name string
+		year int
+		want map[int]int // marriage state of each individual afterwards
+	}{
+		{"random mating", 5, nil},
+		{"only the near woman is close enough", 15, map[int]int{man: near, near: man, far: -1}},
+		{"nobody is close enough", 25, map[int]int{man: -1, near: -1, far: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := types.NewModel()
+			model.Parameters["random_mating"] = 1
+			model.Schedules["random_mating"] = types.Schedule{Step: true, Years: []int{0, 10, 20}, Values: []float64{1, 0.5, 0.1}}
+			model.RNG[rng.Marriage] = rng.NewStream(1)
+			pop := types.NewPop()
+			pop.Add(types.Individual{ID: man, Sex: 0, MarriageState: -1})
+			pop.Add(types.Individual{ID: near, Sex: 1, MarriageState: -1, Lat: 300})
+			pop.Add(types.Individual{ID: far, Sex: 1, MarriageState: -1, Lat: 400, Lon: 400})
+
+			Marriage(model, pop, tt.year)
+
+			if tt.want == nil {
+				// with random mating the man marries one of the two women
+				if wife := pop.Ind(man).MarriageState; wife != near && wife != far {
+					t.Fatalf("man married %d, want %d or %d", wife, near, far)
+				}
+				return
+			}
+			for id, want := range tt.want {
+				if got := pop.Ind(id).MarriageState; got != want {
+					t.Errorf("marriage state of %d = %d, want %d", id, got, want)
+				}
+			}
+		})
+	}
+}
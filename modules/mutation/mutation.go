@@ -1,110 +1,195 @@
 package mutation
 
 import (
+	"drift/modules/coords"
+	"drift/modules/dfe"
+	"drift/modules/genome"
 	"drift/types"
 	"gonum.org/v1/gonum/stat/distuv"
 	"math"
-	"math/rand"
-	"time"
+	"sort"
 )
 
-func InheritMutations(pop *types.Pop, genomemask []uint64, parent int, child int, copy int) {
-	if _, exists := pop.IndMutations[child]; !exists {
-		pop.IndMutations[child] = map[int][]int{
-			0: {},
-			1: {},
-		}
-	}
-	for _, mutationID := range pop.IndMutations[parent][0] {
-		mutation := pop.MutationPool[mutationID]
-		// determine the bit position of the mutation
-		// TO DO: don't hard code "1000000", this applies to the default genome only and will cause problems when multiplier != 1
-		mutationBin := mutation.Position / 1000000
-		inheritedStrand := (genomemask[mutationBin/64] >> (mutationBin % 64)) & 1
-		if int(inheritedStrand) == 0 {
-			pop.IndMutations[child][copy] = append(pop.IndMutations[child][copy], mutationID)
-			pop.MutationCount++
-			pop.MutationPool[mutationID] = mutation
-		}
-	}
-	for _, mutationID := range pop.IndMutations[parent][1] {
-		mutation := pop.MutationPool[mutationID]
-		mutationBin := mutation.Position / 1000000 // See comment above
-		inheritedStrand := (genomemask[mutationBin/64] >> (mutationBin % 64)) & 1
-		if int(inheritedStrand) == 1 {
-			pop.IndMutations[child][copy] = append(pop.IndMutations[child][copy], mutationID)
-			mutation.Count++
-			pop.MutationPool[mutationID] = mutation
+// Draft is a new mutation that has been drawn but not yet given an ID or
+// added to the mutation pool.
+type Draft struct {
+	Position  int
+	Effect    float64
+	Dominance float64
+	Strand    int
+}
+
+// InheritMutations returns the IDs of the mutations a parent passes on in a
+// gamete made with genomemask. As in meiosis, copy 0 of the parent's genome is
+// passed on where the mask bit is set and copy 1 where it is clear, and only
+// where the child carries the bin holding the mutation on that strand (see
+// meiosis). It only reads the population, so it is safe to call for many
+// children at once.
+func InheritMutations(model *types.Model, pop *types.Pop, genomemask genome.Haplotype, carried genome.Haplotype, parent int) []int {
+	var inherited []int
+	for strand := 0; strand <= 1; strand++ {
+		for _, mutationID := range pop.IndMutations[parent][strand] {
+			mutation := pop.MutationPool[mutationID]
+			mutationBin := coords.Bin(model, mutation.Position)
+			if genomemask.Get(mutationBin) == (strand == 0) && carried.Get(mutationBin) {
+				inherited = append(inherited, mutationID)
+			}
 		}
 	}
+	return inherited
 }
 
-func GenerateNewMutations(model *types.Model, pop *types.Pop, ind int) {
-
-	rand.Seed(time.Now().UnixNano())
-	poisson := distuv.Poisson{Lambda: model.Parameters["mu"]}
+// DrawNewMutations draws the de novo mutations for one individual born in the
+// given year from rnd. It does not touch the population, so it is safe to call
+// for many children at once; AddMutations gives the drafts their IDs afterwards.
+// Each mutation is placed at a base-pair position anywhere in the genome.
+func DrawNewMutations(model *types.Model, rnd *types.RandStream, year int) []Draft {
+	poisson := distuv.Poisson{Lambda: model.ParamAt("mu", year), Src: rnd.Source}
 	numNewMutations := int(poisson.Rand())
 
+	drafts := make([]Draft, 0, numNewMutations)
 	for i := 0; i < numNewMutations; i++ {
-		model.FreeParameters["mutID"]++
-		mutationID := model.FreeParameters["mutID"]
-		position := rand.Intn(int(model.FreeParameters["numbits"]))
+		position := rnd.IntN(coords.GenomeLength(model))
 		mutationEffect := 0.0
-		isMutationNonNeutral := rand.Float64()
+		isMutationNonNeutral := rnd.Float64()
 		if isMutationNonNeutral >= model.Parameters["f_neutral"] {
-			mutationEffect = weibullRandom(model.Parameters["shape"], model.Parameters["scale"]) / model.Parameters["Weibull_adj"]
-			isMutationDeleterious := rand.Float64()
+			isMutationDeleterious := rnd.Float64()
 			if isMutationDeleterious > model.Parameters["f_beneficial"] {
-				mutationEffect = -mutationEffect
+				mutationEffect = -dfe.Draw(model, rnd.Rand, dfe.Deleterious)
+			} else {
+				mutationEffect = dfe.Draw(model, rnd.Rand, dfe.Beneficial)
 			}
 		}
-		pop.MutationHist[int(mutationEffect*model.Parameters["mu_scale_factor"])]++
+		drafts = append(drafts, Draft{Position: position, Effect: mutationEffect, Dominance: dominance(model, mutationEffect), Strand: rnd.IntN(2)})
+	}
+	return drafts
+}
 
-		strand := rand.Intn(2)
-		if pop.IndMutations[ind] == nil {
-			pop.IndMutations[ind] = make(map[int][]int)
-		}
-		if pop.IndMutations[ind][strand] == nil {
-			pop.IndMutations[ind][strand] = []int{}
+// AddMutations gives an individual its inherited mutations, one list for the
+// paternal strand and one for the maternal strand, plus its new mutations.
+// New mutations are given IDs and added to the mutation pool.
+func AddMutations(model *types.Model, pop *types.Pop, ind int, inherited [2][]int, drafts []Draft) {
+	if pop.IndMutations[ind] == nil {
+		pop.IndMutations[ind] = make(map[int][]int)
+	}
+	for strand := 0; strand <= 1; strand++ {
+		for _, mutationID := range inherited[strand] {
+			mutation := pop.MutationPool[mutationID]
+			mutation.Count++
+			pop.MutationPool[mutationID] = mutation
+			pop.MutationCount++
 		}
-		pop.IndMutations[ind][strand] = append(pop.IndMutations[ind][strand], mutationID)
+		pop.IndMutations[ind][strand] = append(pop.IndMutations[ind][strand], inherited[strand]...)
+	}
+
+	for _, draft := range drafts {
+		model.FreeParameters["mutID"]++
+		mutationID := model.FreeParameters["mutID"]
+		pop.MutationHist[int(draft.Effect*model.Parameters["mu_scale_factor"])]++
+		pop.IndMutations[ind][draft.Strand] = append(pop.IndMutations[ind][draft.Strand], mutationID)
 		pop.MutationPool[mutationID] = types.Mutation{
 			Id:        mutationID,
-			Position:  position,
-			Effect:    mutationEffect,
+			Position:  draft.Position,
+			Effect:    draft.Effect,
 			Origin:    ind,
 			Count:     1,
-			Dominance: 0,
+			Dominance: draft.Dominance,
 		}
 	}
 }
 
-func weibullRandom(shape, scale float64) float64 {
-	u := rand.Float64()
-	return scale * math.Pow(-math.Log(u), 1/shape)
+// dominance returns the dominance coefficient h of a mutation with the given
+// effect. In the fixed model every mutation has h = dominance. In the effect
+// model, as in Mendel's Accountant, mutations with larger effects are more
+// recessive: h falls from dominance for the smallest effects by a factor of
+// e for every 1/dominance_decay of the mean effect of its kind (deleterious
+// or beneficial).
+func dominance(model *types.Model, effect float64) float64 {
+	h := model.Parameters["dominance"]
+	if model.StringParameters["dominance_model"] == "effect" && effect != 0 {
+		kind := dfe.Deleterious
+		if effect > 0 {
+			kind = dfe.Beneficial
+		}
+		h *= math.Exp(-model.Parameters["dominance_decay"] * math.Abs(effect) / dfe.Mean(model, kind))
+	}
+	return h
 }
 
-func addMutation(pop *types.Pop, ind int, position int, copy int, value int) {
-	if pop.IndMutations[ind] == nil {
-		pop.IndMutations[ind] = make(map[int][]int)
+// CountFitnessAndMutations returns the number of mutations an individual
+// carries and their fitness. A mutation on both strands (the same mutation,
+// inherited from both sides) counts twice its effect, and one on a single
+// strand 2h times its effect, so h = 0.5 is additive. The effects are then
+// combined according to fitness_model.
+func CountFitnessAndMutations(model *types.Model, pop *types.Pop, child int) (int, float64) {
+	strands := pop.IndMutations[child]
+	onStrand := [2]map[int]bool{make(map[int]bool), make(map[int]bool)}
+	for strand := 0; strand <= 1; strand++ {
+		for _, mutationID := range strands[strand] {
+			onStrand[strand][mutationID] = true
+		}
 	}
-	if pop.IndMutations[ind][position] == nil {
-		pop.IndMutations[ind][position] = []int{}
+	var effects []float64
+	for strand := 0; strand <= 1; strand++ {
+		for _, mutationID := range strands[strand] {
+			effect := 0.0
+			if mutation, found := pop.MutationPool[mutationID]; found {
+				if onStrand[1-strand][mutationID] {
+					effect = mutation.Effect
+				} else {
+					effect = 2 * mutation.Dominance * mutation.Effect
+				}
+			}
+			effects = append(effects, effect)
+		}
 	}
-	pop.IndMutations[ind][position][copy] = value
+	return len(effects), Fitness(model, effects)
 }
 
-func CountFitnessAndMutations(pop *types.Pop, child int) (int, float64) {
-	numMutations := 0
-	fitnessEffect := 0.0
-	if len(pop.IndMutations[child]) > 0 {
-		for mutationID, _ := range pop.IndMutations[child] {
-			numMutations++
-			if mutation, found := pop.MutationPool[mutationID]; found {
-				fitnessEffect += mutation.Effect
-			}
+// Fitness combines mutation effects into a fitness, never below 0:
+//
+//   - additive: 1 plus the sum of the effects
+//   - multiplicative: the product of 1 plus each effect
+//   - synergistic: exp(-L - epistasis*L^2/2), where the load L is minus the
+//     sum of the effects, so that each deleterious mutation does more harm
+//     than the last
+//   - curve: the load looked up in the fitness curve, interpolating linearly
+//     between its points and flat beyond its ends
+func Fitness(model *types.Model, effects []float64) float64 {
+	fitness := 1.0
+	switch model.StringParameters["fitness_model"] {
+	case "multiplicative":
+		for _, effect := range effects {
+			fitness *= max(1+effect, 0)
 		}
+	case "synergistic":
+		load := -sum(effects)
+		fitness = math.Exp(-load - model.Parameters["epistasis"]*load*load/2)
+	case "curve":
+		fitness = lookUp(model.FitnessCurve, -sum(effects))
+	default:
+		fitness = 1 + sum(effects)
+	}
+	return max(fitness, 0)
+}
+
+func sum(effects []float64) float64 {
+	total := 0.0
+	for _, effect := range effects {
+		total += effect
+	}
+	return total
+}
+
+// lookUp interpolates the fitness at a load on a fitness curve.
+func lookUp(curve [][2]float64, load float64) float64 {
+	i := sort.Search(len(curve), func(i int) bool { return curve[i][0] >= load })
+	switch {
+	case i == 0:
+		return curve[0][1]
+	case i == len(curve):
+		return curve[i-1][1]
 	}
-	//    print (" FE:", fitnessEffect, ":", numMutations)
-	return numMutations, fitnessEffect
+	lo, hi := curve[i-1], curve[i]
+	return lo[1] + (load-lo[0])/(hi[0]-lo[0])*(hi[1]-lo[1])
 }
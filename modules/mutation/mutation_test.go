@@ -0,0 +1,67 @@
+package mutation
+
+import (
+	"drift/types"
+	"math"
+	"testing"
+)
+
+func TestFitness(t *testing.T) {
+	curve := [][2]float64{{0, 1}, {1, 0.5}, {2, 0}}
+	tests := []struct {
+		name      string
+		model     string
+		epistasis float64
+		effects   []float64
+		want      float64
+	}{
+		{"additive, none", "additive", 0, nil, 1},
+		{"additive", "additive", 0, []float64{-0.1, -0.2, 0.05}, 0.75},
+		{"additive, never below 0", "additive", 0, []float64{-0.7, -0.6}, 0},
+		{"default is additive", "", 0, []float64{-0.25}, 0.75},
+		{"multiplicative", "multiplicative", 0, []float64{-0.5, -0.5}, 0.25},
+		{"multiplicative, beneficial", "multiplicative", 0, []float64{0.5, -0.5}, 0.75},
+		{"multiplicative, lethal", "multiplicative", 0, []float64{-1.5, 0.5}, 0},
+		{"synergistic without epistasis", "synergistic", 0, []float64{-0.5, -0.5}, math.Exp(-1)},
+		{"synergistic", "synergistic", 2, []float64{-0.5, -0.5}, math.Exp(-2)},
+		{"synergistic, none", "synergistic", 2, nil, 1},
+		{"curve", "curve", 0, []float64{-0.25, -0.25}, 0.75},
+		{"curve, beneficial load below the first point", "curve", 0, []float64{0.5}, 1},
+		{"curve, beyond the last point", "curve", 0, []float64{-3}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := types.NewModel()
+			model.StringParameters["fitness_model"] = tt.model
+			model.Parameters["epistasis"] = tt.epistasis
+			model.FitnessCurve = curve
+			if got := Fitness(model, tt.effects); math.Abs(got-tt.want) > 1e-12 {
+				t.Fatalf("Fitness(%v) = %v, want %v", tt.effects, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookUp(t *testing.T) {
+	curve := [][2]float64{{0.5, 0.9}, {1, 0.6}, {3, 0.2}}
+	tests := []struct {
+		name string
+		load float64
+		want float64
+	}{
+		{"before the first point", 0, 0.9},
+		{"at the first point", 0.5, 0.9},
+		{"between points", 0.75, 0.75},
+		{"at a middle point", 1, 0.6},
+		{"further on", 2, 0.4},
+		{"at the last point", 3, 0.2},
+		{"after the last point", 10, 0.2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lookUp(curve, tt.load); math.Abs(got-tt.want) > 1e-12 {
+				t.Fatalf("lookUp(%v) = %v, want %v", tt.load, got, tt.want)
+			}
+		})
+	}
+}
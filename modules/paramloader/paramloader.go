@@ -3,50 +3,344 @@ package paramloader
 import (
 	"drift/modules/csvutils"
 	"drift/types"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 const myFileName = "parameter_defaults.csv"
 
-// Load the parameters from a CSV file and populate the model's Parameters map.
+// Load the parameters from a CSV file and populate the model's Parameters,
+// PlotFlags and StringParameters maps. Every row is checked against the schema,
+// and all of the problems found are reported together.
 func LoadParameters(model *types.Model, configRoot string) error {
-	// Load the CSV file
 	csvLoader := csvutils.CSVLoader{
 		FileName:   myFileName,
 		Dir:        configRoot,
 		MinRecords: 2,
 	}
+	return loadFile(model, csvLoader, true)
+}
+
+// LoadParameterFile layers an alternate parameter file on top of the
+// parameters already loaded. The file may list any subset of the parameters
+// and only needs the parameter and value columns.
+func LoadParameterFile(model *types.Model, path string) error {
+	csvLoader := csvutils.CSVLoader{
+		FileName:   filepath.Base(path),
+		Dir:        filepath.Dir(path),
+		MinRecords: 1,
+	}
+	return loadFile(model, csvLoader, false)
+}
+
+// Override sets a single parameter from a name=value string, as given to the
+// -set command-line flag.
+func Override(model *types.Model, assignment string) error {
+	name, value, ok := strings.Cut(assignment, "=")
+	if !ok {
+		return fmt.Errorf("Error: %q should have the form name=value", assignment)
+	}
+	err := set(model, strings.TrimSpace(name), strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("Error setting %s: %v", assignment, err)
+	}
+	return nil
+}
+
+// loadFile reads a parameter file. A complete file must define every parameter
+// in the schema and have the entry and format columns as well.
+func loadFile(model *types.Model, csvLoader csvutils.CSVLoader, complete bool) error {
 	records, err := csvLoader.LoadCSV()
 	if err != nil {
 		return err
 	}
 
+	// Find the columns from the header row
+	required := []string{"parameter", "value"}
+	if complete {
+		required = []string{"parameter", "entry", "format", "value"}
+	}
+	column := make(map[string]int)
+	for i, heading := range records[0] {
+		column[strings.TrimSpace(heading)] = i
+	}
+	minFields := 0
+	for _, heading := range required {
+		i, ok := column[heading]
+		if !ok {
+			return csvutils.ErrInvalidRecord{CSVLoader: csvLoader, Record: records[0], Message: fmt.Sprintf("No %s column", heading)}
+		}
+		minFields = max(minFields, i+1)
+	}
+	entryCol, hasEntry := column["entry"]
+	formatCol, hasFormat := column["format"]
+	valueCol := column["value"]
+
 	// Skip the header row and process each record
+	var errs []error
+	seen := make(map[string]bool)
 	for _, record := range records[1:] {
-		// Ensure the record has at least 5 fields
-		err := csvLoader.CheckRecord(record, 5)
+		err := csvLoader.CheckRecord(record, minFields)
 		if err != nil {
-			return err
-		}
-
-		// Convert the value based on the group
-		if record[0] == "model_name" {
-			model.ModelName = record[4]
-		} else if record[0] == "map_name" {
-			model.MapName = record[4]
-		} else if record[5] == "Plot" {
-			boolValue, err := csvLoader.ParseBool(record, 4)
-			if err != nil {
-				return err
+			errs = append(errs, err)
+			continue
+		}
+
+		name := record[column["parameter"]]
+		invalid := func(field int, message string) {
+			errs = append(errs, csvutils.ErrInvalidField{CSVLoader: csvLoader, Record: record, Field: field, Message: message})
+		}
+
+		s, known := schema[name]
+		switch {
+		case !known:
+			invalid(column["parameter"], fmt.Sprintf("unknown parameter %q", name))
+			continue
+		case seen[name]:
+			invalid(column["parameter"], fmt.Sprintf("parameter %q is defined more than once", name))
+			continue
+		}
+		seen[name] = true
+
+		if hasFormat && formatCol < len(record) && record[formatCol] != s.format {
+			invalid(formatCol, fmt.Sprintf("%s should have format %s", name, s.format))
+			continue
+		}
+		if hasEntry && entryCol < len(record) {
+			entry := record[entryCol]
+			if !slices.Contains([]string{"Text", "Check", "Dropdown"}, entry) {
+				invalid(entryCol, fmt.Sprintf("%q is not an entry type (Text, Check or Dropdown)", entry))
+				continue
+			}
+			if entry == "Dropdown" && s.choices == nil {
+				invalid(entryCol, fmt.Sprintf("%s has no list of choices for a dropdown", name))
+				continue
 			}
-			model.PlotFlags[record[0]] = boolValue
-		} else {
-			value, err := csvLoader.ParseFloat64(record, 4)
-			if err != nil {
-				return err
+		}
+
+		if err := set(model, name, record[valueCol]); err != nil {
+			invalid(valueCol, err.Error())
+		}
+	}
+
+	if complete {
+		var missing []string
+		for name := range schema {
+			if !seen[name] {
+				missing = append(missing, name)
 			}
-			model.Parameters[record[0]] = value
 		}
+		slices.Sort(missing)
+		for _, name := range missing {
+			errs = append(errs, fmt.Errorf("Error: %s does not define parameter %q", csvLoader.FileName, name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// set checks a value against the schema and stores it in the model.
+func set(model *types.Model, name string, value string) error {
+	s, ok := schema[name]
+	if !ok {
+		return fmt.Errorf("unknown parameter %q", name)
+	}
+	number, str, err := s.parse(value)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
 	}
 
+	switch {
+	case s.plot:
+		model.PlotFlags[name] = number == 1
+	case s.format == "string":
+		model.StringParameters[name] = str
+		switch name {
+		case "model_name":
+			model.ModelName = str
+		case "map_name":
+			model.MapName = str
+		case "scenario":
+			model.Scenario = str
+		}
+	default:
+		model.Parameters[name] = number
+	}
 	return nil
 }
+
+// Validate checks that the parameters make sense together. Call it once all
+// of the parameters have been loaded.
+func Validate(model *types.Model) error {
+	p := model.Parameters
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("Error in parameters: "+format, args...))
+		}
+	}
+
+	check(p["bottleneck_end"] >= p["bottleneck_start"],
+		"bottleneck_end (%v) is before bottleneck_start (%v)", p["bottleneck_end"], p["bottleneck_start"])
+	check(p["seed_year"] <= p["end_year"],
+		"seed_year (%v) is after end_year (%v)", p["seed_year"], p["end_year"])
+	check(p["save_interval"] > 0,
+		"save_interval (%v) must be greater than 0", p["save_interval"])
+	check(p["min_lifespan"] <= p["lifespan"],
+		"min_lifespan (%v) is greater than lifespan (%v)", p["min_lifespan"], p["lifespan"])
+
+	// Seeds
+	mode := model.StringParameters["seed_mode"]
+	immigrant := p["seed_immigrant"] == 1
+	check(p["seed_max_age"] == -1 || p["seed_max_age"] >= p["seed_min_age"],
+		"seed_max_age (%v) is less than seed_min_age (%v)", p["seed_max_age"], p["seed_min_age"])
+	check(mode != "couple" || model.StringParameters["mating_system"] != "hermaphrodite",
+		"a seed couple needs separate sexes")
+	check(!immigrant || mode != "ids",
+		"immigrant seeds cannot be chosen by ID")
+	check(!immigrant || mode == "couple" || model.StringParameters["seed_marital"] != "married",
+		"immigrant seeds arrive unmarried, unless they are a couple")
+	ids, err := SeedIDs(model)
+	check(err == nil, "seed_ids: %v", err)
+	check(err != nil || mode != "ids" || (len(ids) > 0 && len(ids) <= types.MaxSeeds),
+		"seed_ids should list between 1 and %d IDs when seed_mode is ids", types.MaxSeeds)
+
+	// Ancestry labels
+	ancestry := model.StringParameters["ancestry"]
+	check(ancestry == "off" || p["track_DNA"] == 1,
+		"ancestry labels need track_DNA")
+	check(ancestry != "founders" || p["founder_groups"] > 0 || p["start_pop_size"] <= 65535,
+		"with more than 65535 founders (start_pop_size %v), founder_groups must be set", p["start_pop_size"])
+
+	// Fitness
+	check(model.StringParameters["fitness_model"] != "curve" || model.StringParameters["fitness_curve_file"] != "",
+		"the curve fitness model needs a fitness_curve_file")
+
+	// Distributions of mutation effects
+	for _, kind := range []string{"deleterious", "beneficial"} {
+		dfe := model.StringParameters[kind+"_dfe"]
+		if dfe == "mixture" {
+			_, err := Mixture(model, kind)
+			check(err == nil, "%s_mixture: %v", kind, err)
+		}
+		check(dfe != "empirical" || model.StringParameters[kind+"_dfe_file"] != "",
+			"the empirical %s DFE needs a %s_dfe_file", kind, kind)
+	}
+
+	return errors.Join(errs...)
+}
+
+// SeedIDs parses seed_ids, a list of individual IDs separated by semicolons.
+func SeedIDs(model *types.Model) ([]int, error) {
+	var ids []int
+	for _, field := range strings.Split(model.StringParameters["seed_ids"], ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		id, err := strconv.Atoi(field)
+		if err != nil || id < 0 {
+			return nil, fmt.Errorf("%q is not an individual ID", field)
+		}
+		if slices.Contains(ids, id) {
+			return nil, fmt.Errorf("%d is listed more than once", id)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// The classes a mixture of mutation effects can be made of: a fixed effect of
+// 0 or, for deleterious mutations, 1, nearly neutral effects, or one of the
+// distributions that can be chosen for the whole DFE.
+var mixtureClasses = []string{"neutral", "nearly_neutral", "lethal", "gamma", "lognormal", "exponential", "fixed"}
+
+// Mixture parses the mixture parameter for deleterious or beneficial
+// mutations, a list of class:fraction pairs separated by semicolons, e.g.
+// "nearly_neutral:0.5;gamma:0.45;lethal:0.05". The fractions must add up to 1.
+func Mixture(model *types.Model, kind string) ([]types.MixtureClass, error) {
+	var classes []types.MixtureClass
+	total := 0.0
+	for _, field := range strings.Split(model.StringParameters[kind+"_mixture"], ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		class, fraction, found := strings.Cut(field, ":")
+		class = strings.TrimSpace(class)
+		if !found || !slices.Contains(mixtureClasses, class) {
+			return nil, fmt.Errorf("%q should be a class (one of %s) and a fraction", field, strings.Join(mixtureClasses, ", "))
+		}
+		if class == "lethal" && kind == "beneficial" {
+			return nil, fmt.Errorf("beneficial mutations cannot be lethal")
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(fraction), 64)
+		if err != nil || f < 0 {
+			return nil, fmt.Errorf("%q is not a fraction", fraction)
+		}
+		classes = append(classes, types.MixtureClass{Class: class, Fraction: f})
+		total += f
+	}
+	if math.Abs(total-1) > 1e-9 {
+		return nil, fmt.Errorf("the fractions add up to %v, not 1", total)
+	}
+	return classes, nil
+}
+
+// Numeric reports whether name is a model parameter that holds a number,
+// rather than text such as a file name, or a plot flag.
+func Numeric(name string) bool {
+	s, ok := schema[name]
+	return ok && !s.plot && s.format != "string"
+}
+
+// ParseNumeric checks a new value for a numeric parameter that may change
+// while the model runs, such as mu, and converts it.
+func ParseNumeric(name string, value string) (float64, error) {
+	s, ok := schema[name]
+	switch {
+	case !ok:
+		return 0, fmt.Errorf("unknown parameter %q", name)
+	case !Numeric(name):
+		return 0, fmt.Errorf("%s cannot be changed while the model runs", name)
+	}
+	number, _, err := s.parse(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", name, err)
+	}
+	return number, nil
+}
+
+// PrintParameters prints the value of every parameter, so that the output of a
+// run records exactly what it was run with.
+func PrintParameters(model *types.Model) {
+	names := make([]string, 0, len(schema))
+	for name := range schema {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	fmt.Println("Parameters:")
+	for _, name := range names {
+		fmt.Printf("  %s = %s\n", name, Value(model, name))
+	}
+}
+
+// Value returns the current value of a parameter as a string.
+func Value(model *types.Model, name string) string {
+	s := schema[name]
+	switch {
+	case s.plot:
+		return strconv.FormatBool(model.PlotFlags[name])
+	case s.format == "string":
+		return model.StringParameters[name]
+	case s.format == "bool":
+		return strconv.FormatBool(model.Parameters[name] == 1)
+	default:
+		return strconv.FormatFloat(model.Parameters[name], 'f', -1, 64)
+	}
+}
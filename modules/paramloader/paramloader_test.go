@@ -0,0 +1,46 @@
+package paramloader
+
+import (
+	"drift/types"
+	"slices"
+	"testing"
+)
+
+func TestMixture(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		mixture string
+		want    []types.MixtureClass
+		wantErr bool
+	}{
+		{"one class", "deleterious", "gamma:1", []types.MixtureClass{{Class: "gamma", Fraction: 1}}, false},
+		{"several classes", "deleterious", "nearly_neutral:0.5;gamma:0.45;lethal:0.05",
+			[]types.MixtureClass{{Class: "nearly_neutral", Fraction: 0.5}, {Class: "gamma", Fraction: 0.45}, {Class: "lethal", Fraction: 0.05}}, false},
+		{"spaces and a trailing separator", "beneficial", " neutral : 0.25 ; exponential:0.75 ;",
+			[]types.MixtureClass{{Class: "neutral", Fraction: 0.25}, {Class: "exponential", Fraction: 0.75}}, false},
+		{"fractions that round to 1", "deleterious", "gamma:0.1;fixed:0.2;neutral:0.7",
+			[]types.MixtureClass{{Class: "gamma", Fraction: 0.1}, {Class: "fixed", Fraction: 0.2}, {Class: "neutral", Fraction: 0.7}}, false},
+		{"empty", "deleterious", "", nil, true},
+		{"fractions below 1", "deleterious", "gamma:0.5;neutral:0.4", nil, true},
+		{"fractions above 1", "deleterious", "gamma:0.7;neutral:0.4", nil, true},
+		{"unknown class", "deleterious", "weibull:1", nil, true},
+		{"no fraction", "deleterious", "gamma", nil, true},
+		{"not a number", "deleterious", "gamma:half;neutral:0.5", nil, true},
+		{"negative fraction", "deleterious", "gamma:1.5;neutral:-0.5", nil, true},
+		{"lethal beneficial mutations", "beneficial", "lethal:1", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := types.NewModel()
+			model.StringParameters[tt.kind+"_mixture"] = tt.mixture
+			got, err := Mixture(model, tt.kind)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Mixture(%q) error = %v, want error %v", tt.mixture, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Mixture(%q) = %v, want %v", tt.mixture, got, tt.want)
+			}
+		})
+	}
+}
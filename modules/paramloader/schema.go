@@ -0,0 +1,207 @@
+package paramloader
+
+import (
+	"drift/types"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// spec describes what values a parameter may take.
+type spec struct {
+	format       string   // int, float, bool or string, as in the format column
+	min, max     float64  // allowed range for int and float parameters
+	minExclusive bool     // the value must be strictly greater than min
+	choices      []string // allowed values for dropdowns, nil for free text
+	plot         bool     // stored in model.PlotFlags rather than model.Parameters
+}
+
+var noLimit = math.Inf(1)
+
+func intRange(min, max float64) spec   { return spec{format: "int", min: min, max: max} }
+func floatRange(min, max float64) spec { return spec{format: "float", min: min, max: max} }
+func positive() spec                   { return spec{format: "float", min: 0, max: noLimit, minExclusive: true} }
+func boolean() spec                    { return spec{format: "bool"} }
+func text() spec                       { return spec{format: "string"} }
+func dropdown(choices ...string) spec  { return spec{format: "string", choices: choices} }
+func plotFlag() spec                   { return spec{format: "bool", plot: true} }
+
+// Every parameter the model understands. A parameter file that names anything
+// else, or leaves any of these out, is rejected.
+var schema = map[string]spec{
+	// Main
+	"model_name":        text(),
+	"num_runs":          intRange(1, noLimit),
+	"start_pop_size":    intRange(1, noLimit),
+	"max_pop_size":      intRange(1, noLimit),
+	"max_growth_rate":   floatRange(0, noLimit),
+	"end_year":          intRange(0, noLimit),
+	"lifespan":          intRange(1, noLimit),
+	"min_lifespan":      intRange(1, noLimit),
+	"lifespan_drop":     floatRange(0, 1),
+	"maturity":          intRange(0, noLimit),
+	"spacing":           intRange(0, noLimit),
+	"birth_prob":        intRange(1, noLimit),
+	"save_interval":     intRange(1, noLimit),
+	"menopause":         floatRange(0, 1),
+	"bottleneck_start":  intRange(-1, noLimit),
+	"bottleneck_end":    intRange(-1, noLimit),
+	"bottleneck_size":   intRange(0, noLimit),
+	"track_DNA":         boolean(),
+	"track_mutations":   boolean(),
+	"track_dead":        boolean(),
+	"track_map":         boolean(),
+	"map_name":          text(),
+	"max_breeding_inds": intRange(-1, noLimit),
+	"random_mating":     floatRange(0, noLimit),
+	"scenario":          text(),
+	"map_width":         intRange(1, noLimit),
+	"map_height":        intRange(1, noLimit),
+	"animation_delay":   floatRange(0, noLimit),
+	"rng_seed":          intRange(0, 1<<53),
+	"events_file":       text(),
+	"schedules_file":    text(),
+	"engine":            dropdown("years", "generations"),
+	"chromosome_file":   text(),
+	"mating_system":     dropdown("separate_sexes", "hermaphrodite"),
+	"selfing_rate":      floatRange(0, 1),
+
+	// Generations
+	"offspring_per_female":   positive(),
+	"fertility_distribution": dropdown("poisson", "fixed"),
+
+	// DNA
+	"seed_year":           intRange(0, noLimit),
+	"seed_mode":           dropdown("cohort", "couple", "ids"),
+	"num_seeds":           intRange(1, types.MaxSeeds),
+	"seed_ids":            text(),
+	"seed_sex":            dropdown("male", "female", "any"),
+	"seed_min_age":        intRange(-1, noLimit),
+	"seed_max_age":        intRange(-1, noLimit),
+	"seed_marital":        dropdown("any", "married", "unmarried"),
+	"seed_immigrant":      boolean(),
+	"ancestry":            dropdown("off", "seeds", "founders"),
+	"founder_groups":      intRange(0, 65535),
+	"multiplier":          intRange(1, noLimit),
+	"init_heterozygosity": floatRange(0, 1),
+	"genome_map":          boolean(),
+	"every_genome_map":    boolean(),
+
+	// Meiosis
+	"crossover_model":               dropdown("arm", "poisson", "gamma"),
+	"recombination_rate":            floatRange(0, noLimit),
+	"interference":                  positive(),
+	"obligate_chiasma":              boolean(),
+	"recombination_map_file":        text(),
+	"gene_conversion_rate":          floatRange(0, noLimit),
+	"conversion_tract_length":       intRange(1, noLimit),
+	"conversion_tract_distribution": dropdown("geometric", "fixed"),
+
+	// Mutation
+	"mu":                   floatRange(0, noLimit),
+	"f_neutral":            floatRange(0, 1),
+	"f_beneficial":         floatRange(0, 1),
+	"mu_scale_factor":      intRange(1, noLimit),
+	"shape":                positive(),
+	"scale":                positive(),
+	"Weibull_adj":          intRange(1, noLimit),
+	"deleterious_dfe":      dropdown("weibull", "gamma", "lognormal", "exponential", "fixed", "mixture", "empirical"),
+	"deleterious_mean":     positive(),
+	"deleterious_shape":    positive(),
+	"deleterious_mixture":  text(),
+	"deleterious_dfe_file": text(),
+	"beneficial_dfe":       dropdown("weibull", "gamma", "lognormal", "exponential", "fixed", "mixture", "empirical"),
+	"beneficial_mean":      positive(),
+	"beneficial_shape":     positive(),
+	"beneficial_mixture":   text(),
+	"beneficial_dfe_file":  text(),
+	"nearly_neutral_mean":  positive(),
+	"mutation_hist":        boolean(),
+	"mutation_map":         boolean(),
+	"selection":            dropdown("annual", "birth", "both", "truncation", "probability", "unrestricted", "off"),
+	"dominance_model":      dropdown("fixed", "effect"),
+	"dominance":            floatRange(0, 1),
+	"dominance_decay":      floatRange(0, noLimit),
+	"fitness_model":        dropdown("additive", "multiplicative", "synergistic", "curve"),
+	"epistasis":            floatRange(0, noLimit),
+	"fitness_curve_file":   text(),
+
+	// Plot
+	"numinds":                   plotFlag(),
+	"marriages":                 plotFlag(),
+	"births":                    plotFlag(),
+	"random_deaths":             plotFlag(),
+	"cull_deaths":               plotFlag(),
+	"max_ID":                    plotFlag(),
+	"Y_descends":                plotFlag(),
+	"mt_descends":               plotFlag(),
+	"genealo_descends":          plotFlag(),
+	"genetic_descends":          plotFlag(),
+	"num_centromeres":           plotFlag(),
+	"num_blocks":                plotFlag(),
+	"av_block_size":             plotFlag(),
+	"sd_block_size":             plotFlag(),
+	"perc_seed_genome_retained": plotFlag(),
+	"av_seed_genome_coverage":   plotFlag(),
+	"av_heterozygosity":         plotFlag(),
+	"av_ind_fitness":            plotFlag(),
+	"av_bin_fitness":            plotFlag(),
+	"num_mutations":             plotFlag(),
+	"av_mutations_per_bin":      plotFlag(),
+	"av_mutations_per_ind":      plotFlag(),
+}
+
+// parse checks a value against the spec and converts it. Numbers and booleans
+// are returned as a float64 (booleans as 0 or 1), strings as themselves.
+func (s spec) parse(value string) (float64, string, error) {
+	switch s.format {
+	case "int":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, "", fmt.Errorf("%q is not an integer", value)
+		}
+		// parameters are held as float64, which is exact only up to 2^53
+		if int(float64(n)) != n {
+			return 0, "", fmt.Errorf("%d is too large", n)
+		}
+		return float64(n), value, s.checkRange(float64(n))
+	case "float":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) {
+			return 0, "", fmt.Errorf("%q is not a number", value)
+		}
+		return f, value, s.checkRange(f)
+	case "bool":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return 0, "", fmt.Errorf("%q is not a boolean", value)
+		}
+		if b {
+			return 1, value, nil
+		}
+		return 0, value, nil
+	default:
+		if s.choices != nil && !slices.Contains(s.choices, value) {
+			return 0, "", fmt.Errorf("%q is not one of %s", value, strings.Join(s.choices, ", "))
+		}
+		return 0, value, nil
+	}
+}
+
+func (s spec) checkRange(value float64) error {
+	if value < s.min || (s.minExclusive && value == s.min) || value > s.max {
+		lower := "["
+		if s.minExclusive {
+			lower = "("
+		}
+		return fmt.Errorf("%s is outside the allowed range %s%s, %s]", formatNumber(value), lower, formatNumber(s.min), formatNumber(s.max))
+	}
+	return nil
+}
+
+// formatNumber writes a number in full, without an exponent.
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
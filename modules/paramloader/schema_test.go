@@ -0,0 +1,84 @@
+package paramloader
+
+import (
+	"drift/types"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    spec
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{"int", intRange(1, noLimit), "12", 12, false},
+		{"int below the range", intRange(1, noLimit), "0", 0, true},
+		{"int above the range", intRange(0, 10), "11", 0, true},
+		{"int that is a float", intRange(0, 10), "1.5", 0, true},
+		{"int too large for a float64", intRange(0, noLimit), "9007199254740993", 0, true},
+		{"largest exact int", intRange(0, 1<<53), "9007199254740992", 1 << 53, false},
+		{"float", floatRange(0, 1), "0.25", 0.25, false},
+		{"float at the top of the range", floatRange(0, 1), "1", 1, false},
+		{"float outside the range", floatRange(0, 1), "1.01", 0, true},
+		{"not a number", floatRange(0, 1), "NaN", 0, true},
+		{"positive", positive(), "0.1", 0.1, false},
+		{"positive excludes 0", positive(), "0", 0, true},
+		{"bool true", boolean(), "1", 1, false},
+		{"bool false", boolean(), "false", 0, false},
+		{"not a bool", boolean(), "yes", 0, true},
+		{"dropdown choice", dropdown("a", "b"), "b", 0, false},
+		{"not a dropdown choice", dropdown("a", "b"), "c", 0, true},
+		{"free text", text(), "anything at all", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := tt.spec.parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parse(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Fatalf("parse(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverride(t *testing.T) {
+	tests := []struct {
+		name       string
+		assignment string
+		wantErr    bool
+	}{
+		{"number", "mu=0.5", false},
+		{"spaces", " mu = 0.5 ", false},
+		{"string", "selection=birth", false},
+		{"plot flag", "births=1", false},
+		{"no equals sign", "mu", true},
+		{"unknown parameter", "no_such_parameter=1", true},
+		{"bad value", "rng_seed=-1", true},
+		{"most seeds", "num_seeds=64", false},
+		{"more seeds than SeedDescent holds", "num_seeds=65", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := types.NewModel()
+			err := Override(model, tt.assignment)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Override(%q) error = %v, want error %v", tt.assignment, err, tt.wantErr)
+			}
+		})
+	}
+
+	model := types.NewModel()
+	for _, assignment := range []string{"mu=0.5", "selection=birth", "births=1", "model_name=Test"} {
+		if err := Override(model, assignment); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if model.Parameters["mu"] != 0.5 || model.StringParameters["selection"] != "birth" || !model.PlotFlags["births"] || model.ModelName != "Test" {
+		t.Fatalf("Override did not store the values where expected: %v %v %v %q",
+			model.Parameters, model.StringParameters, model.PlotFlags, model.ModelName)
+	}
+}
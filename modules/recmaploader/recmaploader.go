@@ -0,0 +1,130 @@
+package recmaploader
+
+import (
+	"drift/modules/coords"
+	"drift/modules/csvutils"
+	"drift/types"
+	"maps"
+)
+
+// A point on a genetic map: cumulative cM at a position in Mb from the tip
+// of the p arm.
+type point struct {
+	position float64
+	cM       [2]float64 // By sex
+}
+
+// Load the recombination map named by the recombination_map_file parameter,
+// if any, and use it in place of the uniform recombination_rate for the
+// chromosomes it lists. Each row is Chromosome,Position,cM and optionally
+// Female_cM. Position is in Mb from the tip of the p arm and cM is the
+// cumulative genetic position there: the sex-averaged map, or the male map
+// when Female_cM is given. The map starts at 0 cM at position 0, is
+// interpolated linearly between points, and is flat beyond the last point.
+// Hermaphrodites use the average of the two maps.
+func LoadRecombinationMap(model *types.Model, configRoot string) error {
+	fileName := model.StringParameters["recombination_map_file"]
+	if fileName == "" {
+		return nil
+	}
+
+	// Load the CSV file
+	csvLoader := csvutils.CSVLoader{
+		FileName:   fileName,
+		Dir:        configRoot,
+		MinRecords: 2,
+	}
+	records, err := csvLoader.LoadCSV()
+	if err != nil {
+		return err
+	}
+
+	// Skip the header row and process each record
+	points := make(map[int][]point)
+	for _, record := range records[1:] {
+		// Ensure the record has at least 3 fields
+		err := csvLoader.CheckRecord(record, 3)
+		if err != nil {
+			return err
+		}
+		invalid := func(field int, message string) error {
+			return csvutils.ErrInvalidField{CSVLoader: csvLoader, Record: record, Field: field, Message: message}
+		}
+
+		chrom, err := csvLoader.Atoi(record, 0)
+		if err != nil {
+			return err
+		}
+		if _, ok := model.ChromosomeArms[chrom]; !ok {
+			return invalid(0, "There is no such chromosome in the chromosome file")
+		}
+		var p point
+		p.position, err = csvLoader.ParseFloat64(record, 1)
+		if err != nil {
+			return err
+		}
+		p.cM[0], err = csvLoader.ParseFloat64(record, 2)
+		if err != nil {
+			return err
+		}
+		p.cM[1] = p.cM[0]
+		if len(record) > 3 && record[3] != "" {
+			p.cM[1], err = csvLoader.ParseFloat64(record, 3)
+			if err != nil {
+				return err
+			}
+		}
+
+		previous := point{}
+		if n := len(points[chrom]); n > 0 {
+			previous = points[chrom][n-1]
+		}
+		if p.position <= previous.position {
+			return invalid(1, "The positions on each chromosome should increase from 0")
+		}
+		if p.cM[0] < previous.cM[0] || p.cM[1] < previous.cM[1] {
+			return invalid(2, "The cumulative cM should not decrease")
+		}
+		points[chrom] = append(points[chrom], p)
+	}
+
+	// the uniform map is shared by both sexes, so each gets its own copy
+	hermaphrodite := model.StringParameters["mating_system"] == "hermaphrodite"
+	for sex := 0; sex <= 1; sex++ {
+		model.GeneticMap[sex] = maps.Clone(model.GeneticMap[sex])
+		for chrom, chromPoints := range points {
+			model.GeneticMap[sex][chrom] = interpolate(model, chrom, chromPoints, sex, hermaphrodite)
+		}
+	}
+	return nil
+}
+
+// interpolate returns the cumulative Morgans at each bit boundary of a
+// chromosome, for one sex or the average of both.
+func interpolate(model *types.Model, chrom int, points []point, sex int, average bool) []float64 {
+	length := coords.ChromosomeLength(model, chrom)
+	cumulative := make([]float64, length+1)
+	previous := point{}
+	i := 0
+	for bit := range cumulative {
+		position := coords.Mb(model, float64(bit))
+		for i < len(points) && points[i].position < position {
+			previous = points[i]
+			i++
+		}
+		cM := previous.cM
+		if i < len(points) {
+			next := points[i]
+			f := (position - previous.position) / (next.position - previous.position)
+			for s := 0; s <= 1; s++ {
+				cM[s] = previous.cM[s] + f*(next.cM[s]-previous.cM[s])
+			}
+		}
+		if average {
+			cumulative[bit] = (cM[0] + cM[1]) / 2 / 100
+		} else {
+			cumulative[bit] = cM[sex] / 100
+		}
+	}
+	return cumulative
+}
@@ -0,0 +1,85 @@
+package rng
+
+import (
+	"drift/types"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// Names of the random number streams. Each lifecycle stage draws from its own
+// stream so that changing how one stage uses random numbers does not shift the
+// numbers seen by the others.
+const (
+	Init     = "init"
+	Seed     = "seed"
+	Birth    = "birth"
+	Marriage = "marriage"
+	Death    = "death"
+	Mutation = "mutation"
+	Genome   = "genome"
+	Events   = "events"
+)
+
+// The order of this list is part of the seeding scheme, only ever append to it.
+var streamNames = []string{Init, Seed, Birth, Marriage, Death, Mutation, Genome, Events}
+
+// Largest master seed that survives the round trip through model.Parameters.
+const maxSeed = 1 << 53
+
+// SetMasterSeed decides the master seed for the whole model, the rng_seed
+// parameter (which -seed sets), or if that is zero, a seed taken from the
+// clock. The chosen seed is stored in model.Parameters["rng_seed"] and
+// printed so that any run can be repeated.
+func SetMasterSeed(model *types.Model) {
+	if model.Parameters["rng_seed"] == 0 {
+		model.Parameters["rng_seed"] = float64(uint64(time.Now().UnixNano())%(maxSeed-1) + 1)
+	}
+	fmt.Printf("RNG seed: %d\n", int64(model.Parameters["rng_seed"]))
+}
+
+// NewRunStreams gives the model a fresh set of random number streams for the
+// given run. The streams depend only on the master seed, the run number and
+// the stream name.
+func NewRunStreams(model *types.Model, run int) {
+	master := uint64(int64(model.Parameters["rng_seed"]))
+	model.RNG = make(map[string]*types.RandStream, len(streamNames))
+	for i, name := range streamNames {
+		model.RNG[name] = NewStream(master, uint64(run), uint64(i))
+	}
+}
+
+// NewIndividualStream returns a stream for the random numbers that belong to
+// one individual, such as the meiosis masks and new mutations of a newborn.
+// It depends only on the master seed, the run, the stream name and the ID, so
+// individuals can be handled in any order, or in parallel, without changing
+// the results.
+func NewIndividualStream(model *types.Model, name string, id int) *types.RandStream {
+	index := slices.Index(streamNames, name)
+	if index < 0 {
+		panic(fmt.Sprintf("rng: unknown stream %q", name))
+	}
+	master := uint64(int64(model.Parameters["rng_seed"]))
+	return NewStream(master, uint64(model.FreeParameters["run"]), uint64(index), uint64(id))
+}
+
+// NewStream creates a stream from a master seed and any number of keys. The
+// keys are mixed into the seed so that neighbouring keys give unrelated streams.
+func NewStream(master uint64, keys ...uint64) *types.RandStream {
+	seed1 := splitmix64(master)
+	for _, key := range keys {
+		seed1 = splitmix64(seed1 ^ key)
+	}
+	seed2 := splitmix64(seed1)
+	source := rand.NewPCG(seed1, seed2)
+	return &types.RandStream{Rand: rand.New(source), Source: source}
+}
+
+// splitmix64 is the finalizer from Steele et al.'s SplitMix generator.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
@@ -1,19 +1,56 @@
 package save
 
 import (
+	"drift/modules/coords"
+	"drift/modules/genome"
+	"drift/modules/stage"
 	"drift/types"
 	"encoding/csv"
 	"fmt"
 	"image"
 	"image/color"
 	"image/png"
+	"maps"
+	"math"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 )
 
-// SaveHeaders creates a CSV file with the headers for the results
-func SaveHeaders(modelName string) error {
-	filename := fmt.Sprintf("results/%s_results.csv", modelName)
+// Guards the results file, which all runs of a model append to
+var resultsMutex sync.Mutex
+
+func init() {
+	stage.Register(stage.Save, stage.Default, stage.StageFunc(
+		func(model *types.Model, pop *types.Pop, year int) error {
+			Save(model, pop, model.FreeParameters["run"], year)
+			return nil
+		}))
+}
+
+// SaveHeaders creates CSV files with the headers for the results, the
+// per-seed results and the per-label ancestry results. Any key columns, such as the parameters of a sweep, come
+// before the usual headers.
+func SaveHeaders(modelName string, keys ...string) error {
+	headers := append(slices.Clone(keys),
+		"run", "year", "n", "marrs", "births", "randDs", "cullDs", "GenetDes", "GeneaDes", "YDes", "MtDes",
+		"nCents", "nAlleles", "nBlocks", "TotFitness", "nMuts", "PercSeedGenoRet", "AvSeedGenoCov", "nHet", "nHomMin", "nHomMaj",
+		"AvBlockSize", "SdBlockSize", "nHemi", "PercXRet", "PercYRet",
+	)
+	if err := writeHeaders(fmt.Sprintf("results/%s_results.csv", modelName), headers); err != nil {
+		return err
+	}
+	seedHeaders := append(slices.Clone(keys), "run", "year", "seed", "id", "GeneaDes")
+	if err := writeHeaders(fmt.Sprintf("results/%s_seeds.csv", modelName), seedHeaders); err != nil {
+		return err
+	}
+	ancestryHeaders := append(slices.Clone(keys), "run", "year", "label", "GeneaDes", "GenetDes", "PercContrib", "PercRet")
+	return writeHeaders(fmt.Sprintf("results/%s_ancestry.csv", modelName), ancestryHeaders)
+}
+
+func writeHeaders(filename string, headers []string) error {
 	file, err := os.OpenFile(filename, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %v", err)
@@ -21,20 +58,70 @@ func SaveHeaders(modelName string) error {
 	defer file.Close()
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
-	headers := []string{
-		"run", "year", "n", "marrs", "births", "randDs", "cullDs", "GenetDes", "GeneaDes", "YDes", "MtDes",
-		"nCents", "nAlleles", "nBlocks", "TotFitness", "nMuts", "PercSeedGenoRet", "AvSeedGenoCov", "AvHet",
-	}
 	if err := writer.Write(headers); err != nil {
 		return fmt.Errorf("failed to write headers: %v", err)
 	}
 	return nil
 }
 
+// TruncateResults drops every row of the results files that comes after the
+// given run and year. When a model is resumed from a checkpoint, rows written
+// between the checkpoint and the interruption would otherwise appear twice.
+func TruncateResults(modelName string, run int, year int) error {
+	err := truncate(fmt.Sprintf("results/%s_results.csv", modelName), run, year)
+	if err != nil {
+		return err
+	}
+	err = truncate(fmt.Sprintf("results/%s_seeds.csv", modelName), run, year)
+	if err != nil {
+		return err
+	}
+	return truncate(fmt.Sprintf("results/%s_ancestry.csv", modelName), run, year)
+}
+
+func truncate(filename string, run int, year int) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", filename, err)
+	}
+
+	kept := records[:0]
+	for i, record := range records {
+		if i > 0 {
+			rowRun, err1 := strconv.Atoi(record[0])
+			rowYear, err2 := strconv.Atoi(record[1])
+			if err1 != nil || err2 != nil {
+				return fmt.Errorf("bad row in %s: %v", filename, record)
+			}
+			if rowRun > run || (rowRun == run && rowYear > year) {
+				continue
+			}
+		}
+		kept = append(kept, record)
+	}
+
+	file, err = os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	writer.WriteAll(kept)
+	return writer.Error()
+}
+
 // Save writes the current simulation state to a CSV file
 
 func Save(model *types.Model, pop *types.Pop, run int, year int) {
-	fmt.Printf("   Year: %d  n: %d  b: %d  m: %d c: %d\n",
+	fmt.Printf("   Run: %d  Year: %d  n: %d  b: %d  m: %d c: %d\n",
+		run,
 		year,
 		model.FreeParameters["last_pop_size"],
 		pop.Tracking["births"],
@@ -42,16 +129,30 @@ func Save(model *types.Model, pop *types.Pop, run int, year int) {
 		pop.Tracking["cull_deaths"],
 	)
 
-	filename := fmt.Sprintf("results/%s_results.csv", model.ModelName)
+	// All points of a sweep share one results file, keyed by the swept values
+	name := model.ModelName
+	var key []string
+	if model.Sweep != nil {
+		name = model.Sweep.Name
+		key = append([]string{strconv.Itoa(model.Sweep.Index)}, model.Sweep.Values...)
+	}
+	filename := fmt.Sprintf("results/%s_results.csv", name)
 	numInds := len(pop.IndData)
 	var YDescends, mtDescends, genealoDescends, geneticDescends, numAlleles, numBlocks, numCentromeres, numMutations, popFitness int
 	var percSeedGenomeRetained, avSeedGenomeCoverage float64
-	var totHet, totHomMin, totHomMaj, numbitsRetained int
+	var totHet, totHomMin, totHomMaj, totHemi, numbitsRetained int
+	var percXRetained, percYRetained float64
+	var avBlockSize, sdBlockSize float64
 
 	if model.Parameters["track_DNA"] == 1 {
 		YDescends, mtDescends, genealoDescends, geneticDescends, numAlleles, numBlocks, numCentromeres = calculateMiscStats(pop.IndData)
-		numbitsRetained, totHet, totHomMin, totHomMaj = seedCounts(model, pop)
-		percSeedGenomeRetained = float64(numbitsRetained) / float64(model.FreeParameters["NumBits"]) * 100
+		var seedGenomeRetained genome.Haplotype
+		seedGenomeRetained, totHet, totHomMin, totHomMaj, totHemi = seedCounts(model, pop)
+		numbitsRetained = seedGenomeRetained.Count()
+		percSeedGenomeRetained = float64(numbitsRetained) / float64(model.FreeParameters["numbits"]) * 100
+		percXRetained = percChromosomeRetained(model, seedGenomeRetained, model.SexChromosomes.X)
+		percYRetained = percChromosomeRetained(model, seedGenomeRetained, model.SexChromosomes.Y)
+		avBlockSize, sdBlockSize = tractStats(model, pop)
 		avSeedGenomeCoverage = 0
 	}
 
@@ -63,12 +164,15 @@ func Save(model *types.Model, pop *types.Pop, run int, year int) {
 
 	}
 
+	// Runs executing at the same time share the results file
+	resultsMutex.Lock()
+	defer resultsMutex.Unlock()
 	file, _ := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	defer file.Close()
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
-	data := []string{
+	data := append(key,
 		fmt.Sprintf("%d", run),
 		fmt.Sprintf("%d", year),
 		fmt.Sprintf("%d", numInds),
@@ -87,12 +191,56 @@ func Save(model *types.Model, pop *types.Pop, run int, year int) {
 		fmt.Sprintf("%d", numMutations),
 		fmt.Sprintf("%.1f", percSeedGenomeRetained),
 		fmt.Sprintf("%.1f", avSeedGenomeCoverage),
-		fmt.Sprintf("%.2f", totHet),
-		fmt.Sprintf("%.2f", totHomMin),
-		fmt.Sprintf("%.2f", totHomMaj),
-	}
+		fmt.Sprintf("%d", totHet),
+		fmt.Sprintf("%d", totHomMin),
+		fmt.Sprintf("%d", totHomMaj),
+		fmt.Sprintf("%.2f", avBlockSize),
+		fmt.Sprintf("%.2f", sdBlockSize),
+		fmt.Sprintf("%d", totHemi),
+		fmt.Sprintf("%.1f", percXRetained),
+		fmt.Sprintf("%.1f", percYRetained),
+	)
 	writer.Write(data)
 
+	// One row per seed, with the number of their living descendants
+	if model.Parameters["track_DNA"] == 1 {
+		seedFile, _ := os.OpenFile(fmt.Sprintf("results/%s_seeds.csv", name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		defer seedFile.Close()
+		seedWriter := csv.NewWriter(seedFile)
+		defer seedWriter.Flush()
+		for i, seed := range pop.Seeds {
+			seedWriter.Write(append(slices.Clone(key),
+				fmt.Sprintf("%d", run),
+				fmt.Sprintf("%d", year),
+				fmt.Sprintf("%d", i+1),
+				fmt.Sprintf("%d", seed),
+				fmt.Sprintf("%d", countDescendants(pop, i+1)),
+			))
+		}
+	}
+
+	// One row per ancestry label, with its genealogical and genetic descendants
+	if model.StringParameters["ancestry"] != "off" {
+		ancestryFile, _ := os.OpenFile(fmt.Sprintf("results/%s_ancestry.csv", name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		defer ancestryFile.Close()
+		ancestryWriter := csv.NewWriter(ancestryFile)
+		defer ancestryWriter.Flush()
+		for label, counts := range ancestryCounts(model, pop) {
+			if label == 0 {
+				continue
+			}
+			ancestryWriter.Write(append(slices.Clone(key),
+				fmt.Sprintf("%d", run),
+				fmt.Sprintf("%d", year),
+				fmt.Sprintf("%d", label),
+				fmt.Sprintf("%d", counts.genealogical),
+				fmt.Sprintf("%d", counts.genetic),
+				fmt.Sprintf("%.2f", counts.percContributed),
+				fmt.Sprintf("%.1f", counts.percRetained),
+			))
+		}
+	}
+
 	pop.Tracking["births"] = 0
 	pop.Tracking["deaths"] = 0
 	pop.Tracking["marriages"] = 0
@@ -114,17 +262,16 @@ func Save(model *types.Model, pop *types.Pop, run int, year int) {
 // personDataString formats detailed individual data with state information
 func personDataString(pop *types.Pop, ind int, year int, state string) string {
 	var info strings.Builder
-	indInfo, exists := pop.IndData[ind]
-	if exists {
-		fields := []string{
-			"birth_year", "sex", "dad", "mom", "lifespan",
-			"lat", "lon", "marriage_state", "numbirths",
-			"Y_gens", "mt_gens", "min_genealo_gens", "max_genealo_gens",
-			"allele_count", "num_blocks", "centromeres", "fitness", "mutations",
+	if person := pop.Ind(ind); person != nil {
+		fields := []int{
+			person.BirthYear, person.Sex, person.Dad, person.Mom, person.Lifespan,
+			person.Lat, person.Lon, person.MarriageState, person.NumBirths,
+			person.YGens, person.MtGens, person.MinGenealoGens, person.MaxGenealoGens,
+			person.AlleleCount, person.NumBlocks, person.NumCentromeres, person.Fitness, person.NumMutations,
 		}
-		info.WriteString(fmt.Sprintf("%d,%d,%d,", ind, getOrDefault(indInfo, "birth_year", -1), year))
+		info.WriteString(fmt.Sprintf("%d,%d,%d,", ind, person.BirthYear, year))
 		for _, field := range fields {
-			info.WriteString(fmt.Sprintf("%d,", getOrDefault(indInfo, field, -1)))
+			info.WriteString(fmt.Sprintf("%d,", field))
 		}
 		info.WriteString(state) // Append state ('R' for removed, 'A' for alive)
 		info.WriteString("\n")
@@ -132,14 +279,6 @@ func personDataString(pop *types.Pop, ind int, year int, state string) string {
 	return info.String()
 }
 
-// getOrDefault retrieves a value from a map with a default fallback
-func getOrDefault(data map[string]int, key string, defaultVal int) int {
-	if val, ok := data[key]; ok {
-		return val
-	}
-	return defaultVal
-}
-
 // writeToFile writes content to a file
 func writeToFile(filename string, content string) error {
 	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
@@ -157,43 +296,88 @@ func writeToFile(filename string, content string) error {
 
 // SaveGenomeMap saves the chromosomes data as an image with rows representing individuals and columns as bit positions.
 func SaveGenomeMap(
-	chromosomes map[int][][]uint64,
-	chromosomeArms map[int]map[int][]int,
+	model *types.Model,
+	chromosomes map[int][]genome.Haplotype,
 	fileName string,
 	pixelSize int,
-	numbits int,
 ) error {
-	nIndividuals := len(chromosomes)
-	imgWidth := numbits*pixelSize + len(chromosomeArms)*4 + 500
+	red := color.RGBA{255, 0, 0, 255}
+	black := color.RGBA{0, 0, 0, 255}
+	return drawGenomeMap(slices.Sorted(maps.Keys(chromosomes)), func(ind int, genomecopy int, bitPos int) color.RGBA {
+		if chromosomes[ind][genomecopy].Get(bitPos) {
+			return red
+		}
+		return black
+	}, model, fileName, pixelSize)
+}
+
+// SaveAncestryMap saves a genome map like SaveGenomeMap, with each bit
+// coloured by its ancestry label. Unlabelled bits are black.
+func SaveAncestryMap(
+	model *types.Model,
+	ancestry map[int][]types.Segments,
+	fileName string,
+	pixelSize int,
+) error {
+	return drawGenomeMap(slices.Sorted(maps.Keys(ancestry)), func(ind int, genomecopy int, bitPos int) color.RGBA {
+		return labelColor(ancestry[ind][genomecopy].At(bitPos))
+	}, model, fileName, pixelSize)
+}
+
+// labelColor picks a bright colour for an ancestry label, stepping round the
+// colour wheel by the golden angle so that neighbouring labels stand apart.
+func labelColor(label uint16) color.RGBA {
+	if label == 0 {
+		return color.RGBA{0, 0, 0, 255}
+	}
+	hue := math.Mod(float64(label)*0.618033988749895, 1) * 6
+	x := uint8(255 * (1 - math.Abs(math.Mod(hue, 2)-1)))
+	switch int(hue) {
+	case 0:
+		return color.RGBA{255, x, 0, 255}
+	case 1:
+		return color.RGBA{x, 255, 0, 255}
+	case 2:
+		return color.RGBA{0, 255, x, 255}
+	case 3:
+		return color.RGBA{0, x, 255, 255}
+	case 4:
+		return color.RGBA{x, 0, 255, 255}
+	}
+	return color.RGBA{255, 0, x, 255}
+}
+
+// drawGenomeMap draws two rows per individual, one for each genome copy,
+// taking the colour of each bit from colorOf.
+func drawGenomeMap(
+	inds []int,
+	colorOf func(ind int, genomecopy int, bitPos int) color.RGBA,
+	model *types.Model,
+	fileName string,
+	pixelSize int,
+) error {
+	nIndividuals := len(inds)
+	numChromosomes := len(model.ChromosomeArms)
+	imgWidth := model.FreeParameters["numbits"]*pixelSize + numChromosomes*4 + 500
 	imgHeight := nIndividuals * 2 * pixelSize
 
 	// Create a blank image
 	img := image.NewRGBA(image.Rect(0, 0, imgWidth, imgHeight))
-	red := color.RGBA{255, 0, 0, 255}
 	green := color.RGBA{0, 255, 0, 255}
-	black := color.RGBA{0, 0, 0, 255}
 	centromereColor := color.RGBA{100, 100, 100, 255}
 	chromosomeBoundaryColor := green
-	color := red
 	counter := 0
 	spacer := 0
-	for _, chromosomeData := range chromosomes {
+	for _, ind := range inds {
 		counter += 1
 		for genomecopy := 0; genomecopy < 2; genomecopy++ {
 			spacer = 0
-			sequenceData := chromosomeData[genomecopy]
 			startY := (counter*2 + genomecopy) * pixelSize
-			for chromosome := 1; chromosome < len(chromosomeArms); chromosome++ {
+			for chromosome := 1; chromosome <= numChromosomes; chromosome++ {
 				// Draw p arm bits
-				pStart := chromosomeArms[chromosome][0][0]
-				pLength := chromosomeArms[chromosome][0][1]
+				pStart, pLength := coords.Arm(model, chromosome, 0)
 				for bitPos := pStart; bitPos < pStart+pLength; bitPos++ {
-					bitValue := (sequenceData[bitPos/64] >> (bitPos % 64)) & 1
-					if bitValue == 0 {
-						color = black
-					} else {
-						color = red
-					}
+					color := colorOf(ind, genomecopy, bitPos)
 					for y := 0; y < pixelSize; y++ {
 						for x := 0; x < pixelSize; x++ {
 							img.Set(bitPos*pixelSize+x+spacer, startY+y, color)
@@ -211,15 +395,9 @@ func SaveGenomeMap(
 				spacer += pixelSize
 
 				// Draw q arm bits
-				qStart := chromosomeArms[chromosome][1][0]
-				qLength := chromosomeArms[chromosome][1][1]
+				qStart, qLength := coords.Arm(model, chromosome, 1)
 				for bitPos := qStart; bitPos < qStart+qLength; bitPos++ {
-					bitValue := (sequenceData[bitPos/64] >> (bitPos % 64)) & 1
-					if bitValue == 0 {
-						color = black
-					} else {
-						color = red
-					}
+					color := colorOf(ind, genomecopy, bitPos)
 					for y := 0; y < pixelSize; y++ {
 						for x := 0; x < pixelSize; x++ {
 							img.Set(bitPos*pixelSize+x+spacer, startY+y, color)
@@ -254,88 +432,173 @@ func SaveGenomeMap(
 	return nil
 }
 
-func calculateMiscStats(indData map[int]map[string]int) (int, int, int, int, int, int, int) {
+func calculateMiscStats(indData []types.Individual) (int, int, int, int, int, int, int) {
 	var Y, mt, genealo, genetic, alleles, blocks, cents int
-	for _, ind := range indData {
-		if ind["Y_gens"] > 0 {
+	for i := range indData {
+		ind := &indData[i]
+		if ind.YGens > 0 {
 			Y += 1
 		}
-		if ind["mt_gens"] > 0 {
+		if ind.MtGens > 0 {
 			mt += 1
 		}
-		if ind["max_genealo_gens"] > -1 {
+		if ind.MaxGenealoGens > -1 {
 			genealo++
 		}
-		if ind["allele_count"] > 0 {
-			alleles += ind["allele_count"]
+		if ind.AlleleCount > 0 {
+			alleles += ind.AlleleCount
 			genetic++
 		}
-		if ind["num_blocks"] > 0 {
-			blocks += ind["num_blocks"]
+		if ind.NumBlocks > 0 {
+			blocks += ind.NumBlocks
 		}
-		if ind["num_centromeres"] > 0 {
-			cents += ind["num_centromeres"]
+		if ind.NumCentromeres > 0 {
+			cents += ind.NumCentromeres
 		}
 	}
 	return Y, mt, genealo, genetic, alleles, blocks, cents
 }
 
-func seedCounts(model *types.Model, pop *types.Pop) (int, int, int, int) {
+// countDescendants returns the number of living individuals descended from
+// the seed with the given label, including the seed.
+func countDescendants(pop *types.Pop, label int) int {
+	descendants := 0
+	for i := range pop.IndData {
+		if pop.IndData[i].SeedDescent&(1<<(label-1)) != 0 {
+			descendants++
+		}
+	}
+	return descendants
+}
+
+// seedCounts returns the seed bits retained anywhere in the population, and
+// totals of the heterozygous, homozygous and hemizygous seed bits. Only the
+// bits an individual carries on both strands count as heterozygous or
+// homozygous, and hemizygous bits are those on a sex chromosome carried on
+// one strand only.
+func seedCounts(model *types.Model, pop *types.Pop) (genome.Haplotype, int, int, int, int) {
 
-	bitCounts := make([]int, model.FreeParameters["NumBits"])
-	totHet, totHomMin, totHomMaj := 0, 0, 0
-	seedGenomeRetained := make([]uint64, (model.FreeParameters["NumBits"]+63)/64)
+	numbits := model.FreeParameters["numbits"]
+	totHet, totHomMin, totHomMaj, totHemi := 0, 0, 0, 0
+	seedGenomeRetained := genome.New(numbits)
 
-	for _, chromosomePairs := range pop.Chromosomes {
+	for ind, chromosomePairs := range pop.Chromosomes {
 		if len(chromosomePairs) > 0 && len(chromosomePairs[0]) > 0 && len(chromosomePairs[1]) > 0 {
-			seedGenomeRetained = bitwiseOR(seedGenomeRetained, chromosomePairs[0])
-			seedGenomeRetained = bitwiseOR(seedGenomeRetained, chromosomePairs[1])
-			for j := range chromosomePairs[0] {
-				b0, b1 := chromosomePairs[0][j], chromosomePairs[1][j]
-				bitCounts[j] += countSetBitsSingleVar(b0)
-				bitCounts[j] += countSetBitsSingleVar(b1)
-				xorBits := b0 ^ b1
-				andBits := b0 & b1
-				norBits := ^(b0 | b1)
-				totHet += countSetBitsSingleVar(xorBits)
-				totHomMin += countSetBitsSingleVar(andBits)
-				totHomMaj += countSetBitsSingleVar(norBits)
-			}
+			copy0, copy1 := chromosomePairs[0], chromosomePairs[1]
+			carried := model.SexChromosomes.Carried[pop.Ind(ind).Sex]
+			diploid := genome.And(carried[0], carried[1])
+			seedGenomeRetained = genome.Or(seedGenomeRetained, copy0)
+			seedGenomeRetained = genome.Or(seedGenomeRetained, copy1)
+			totHet += genome.And(genome.Xor(copy0, copy1), diploid).CountRange(0, numbits)
+			totHomMin += genome.And(genome.And(copy0, copy1), diploid).CountRange(0, numbits)
+			totHomMaj += genome.And(genome.Nor(copy0, copy1), diploid).CountRange(0, numbits)
+			seedBits := genome.Or(copy0, copy1)
+			totHemi += seedBits.Count() - genome.And(seedBits, diploid).Count()
 		}
 	}
-	numbitsRetained := countSetBits(seedGenomeRetained)
-	return numbitsRetained, totHet, totHomMin, totHomMaj
+	return seedGenomeRetained, totHet, totHomMin, totHomMaj, totHemi
 }
 
-func calculateFitnessStats(model *types.Model, pop *types.Pop) (numMuts int, totalFitness int) {
-	for _, ind := range pop.IndData {
-		numMuts += ind["num_mutations"]
-		totalFitness += ind["fitness"]
+// labelCounts holds the ancestry results for one founder label.
+type labelCounts struct {
+	genealogical    int     // Living individuals descended from the label
+	genetic         int     // Living individuals carrying at least one bit from the label
+	percContributed float64 // Percentage of the population's bits from the label
+	percRetained    float64 // Percentage of the genome carried from the label by someone
+}
+
+// ancestryCounts is seedCounts for many labels. It returns the counts for
+// each label, indexed by label, with index 0 for unlabelled bits.
+func ancestryCounts(model *types.Model, pop *types.Pop) []labelCounts {
+	numbits := model.FreeParameters["numbits"]
+	numLabels := model.FreeParameters["num_labels"]
+	counts := make([]labelCounts, numLabels+1)
+	bitsFrom := make([]int, numLabels+1)
+	retained := make([]genome.Haplotype, numLabels+1)
+	for label := range retained {
+		retained[label] = genome.New(numbits)
 	}
-	return numMuts, totalFitness
+	totalBits := 0
+	carries := make([]bool, numLabels+1)
+	for i := range pop.IndData {
+		ind := pop.IndData[i].ID
+		carried := model.SexChromosomes.Carried[pop.IndData[i].Sex]
+		totalBits += carried[0].Count() + carried[1].Count()
+		for _, label := range pop.Descent[ind] {
+			counts[label].genealogical++
+		}
+		labels, ok := pop.Ancestry[ind]
+		if !ok {
+			continue
+		}
+		clear(carries)
+		for _, segments := range labels {
+			for _, segment := range segments {
+				bitsFrom[segment.Label] += segment.End - segment.Start
+				retained[segment.Label].SetRange(segment.Start, segment.End)
+				carries[segment.Label] = true
+			}
+		}
+		for label, ok := range carries {
+			if ok {
+				counts[label].genetic++
+			}
+		}
+	}
+	for label := 1; label <= numLabels; label++ {
+		if totalBits > 0 {
+			counts[label].percContributed = float64(bitsFrom[label]) / float64(totalBits) * 100
+		}
+		counts[label].percRetained = float64(retained[label].Count()) / float64(numbits) * 100
+	}
+	return counts
 }
 
-func bitwiseOR(a, b []uint64) []uint64 {
-	result := make([]uint64, len(a))
-	for i := range a {
-		result[i] = a[i] | b[i]
+// percChromosomeRetained returns the percentage of a chromosome's bits that
+// the population has retained from the seed, or 0 if there is no such
+// chromosome.
+func percChromosomeRetained(model *types.Model, seedGenomeRetained genome.Haplotype, chrom int) float64 {
+	if chrom == 0 {
+		return 0
+	}
+	var retained, length int
+	for arm := 0; arm <= 1; arm++ {
+		start, armLength := coords.Arm(model, chrom, arm)
+		retained += seedGenomeRetained.CountRange(start, start+armLength)
+		length += armLength
 	}
-	return result
+	return float64(retained) / float64(length) * 100
 }
 
-func countSetBitsSingleVar(value uint64) int {
-	count := 0
-	for value > 0 {
-		count += int(value & 1)
-		value >>= 1
+// tractStats returns the mean and standard deviation of the lengths, in bits,
+// of the blocks of seed DNA carried by the population.
+func tractStats(model *types.Model, pop *types.Pop) (float64, float64) {
+	var n, sum, sumsq float64
+	for _, chromosomePairs := range pop.Chromosomes {
+		for _, haplotype := range chromosomePairs {
+			for chrom := 1; chrom <= len(model.ChromosomeArms); chrom++ {
+				for arm := 0; arm <= 1; arm++ {
+					start, length := coords.Arm(model, chrom, arm)
+					haplotype.Runs(start, start+length, func(_ int, tract int) {
+						n++
+						sum += float64(tract)
+						sumsq += float64(tract) * float64(tract)
+					})
+				}
+			}
+		}
 	}
-	return count
+	if n == 0 {
+		return 0, 0
+	}
+	mean := sum / n
+	return mean, math.Sqrt(math.Max(sumsq/n-mean*mean, 0))
 }
 
-func countSetBits(data []uint64) int {
-	count := 0
-	for _, value := range data {
-		count += countSetBitsSingleVar(value)
+func calculateFitnessStats(model *types.Model, pop *types.Pop) (numMuts int, totalFitness int) {
+	for i := range pop.IndData {
+		numMuts += pop.IndData[i].NumMutations
+		totalFitness += pop.IndData[i].Fitness
 	}
-	return count
+	return numMuts, totalFitness
 }
@@ -0,0 +1,86 @@
+package scheduleloader
+
+import (
+	"drift/modules/csvutils"
+	"drift/modules/paramloader"
+	"drift/types"
+	"fmt"
+	"slices"
+)
+
+// Parameters that the lifecycle stages look up year by year, and so can be
+// given a schedule.
+var schedulable = []string{"mu", "birth_prob", "max_growth_rate", "menopause", "lifespan_drop", "random_mating"}
+
+// Load the schedules file named by the schedules_file parameter, if any, and
+// populate the model's Schedules map. Each row is parameter,shape,year,value,
+// where the shape is linear (values between points are interpolated) or step
+// (each value holds until the next point). All the rows for one parameter must
+// have the same shape, and their years must increase.
+func LoadSchedules(model *types.Model, configRoot string) error {
+	fileName := model.StringParameters["schedules_file"]
+	if fileName == "" {
+		return nil
+	}
+
+	// Load the CSV file
+	csvLoader := csvutils.CSVLoader{
+		FileName:   fileName,
+		Dir:        configRoot,
+		MinRecords: 2,
+	}
+	records, err := csvLoader.LoadCSV()
+	if err != nil {
+		return err
+	}
+
+	// Skip the header row and process each record
+	for _, record := range records[1:] {
+		// Ensure the record has at least 4 fields
+		err := csvLoader.CheckRecord(record, 4)
+		if err != nil {
+			return err
+		}
+		invalid := func(field int, message string) error {
+			return csvutils.ErrInvalidField{CSVLoader: csvLoader, Record: record, Field: field, Message: message}
+		}
+
+		name, shape := record[0], record[1]
+		if !slices.Contains(schedulable, name) {
+			return invalid(0, fmt.Sprintf("%s cannot be scheduled, only %v", name, schedulable))
+		}
+		if shape != "linear" && shape != "step" {
+			return invalid(1, "The shape should be linear or step")
+		}
+		year, err := csvLoader.Atoi(record, 2)
+		if err != nil {
+			return err
+		}
+		value, err := paramloader.ParseNumeric(name, record[3])
+		if err != nil {
+			return invalid(3, err.Error())
+		}
+
+		schedule, exists := model.Schedules[name]
+		switch {
+		case !exists:
+			schedule.Step = shape == "step"
+		case schedule.Step != (shape == "step"):
+			return invalid(1, fmt.Sprintf("All of the points for %s should have the same shape", name))
+		case year <= schedule.Years[len(schedule.Years)-1]:
+			return invalid(2, fmt.Sprintf("The years for %s should increase", name))
+		}
+		schedule.Years = append(schedule.Years, year)
+		schedule.Values = append(schedule.Values, value)
+		model.Schedules[name] = schedule
+	}
+
+	// A scheduled parameter cannot also be changed by an event
+	for _, event := range model.Events {
+		if _, ok := model.Schedules[event.Action]; ok {
+			return fmt.Errorf("Error: %s has a schedule in %s and is also changed by an event in year %d",
+				event.Action, fileName, event.Year)
+		}
+	}
+	return nil
+}
@@ -1,78 +1,244 @@
 package seedpopulation
 
 import (
+	"drift/modules/ancestry"
+	"drift/modules/genome"
+	"drift/modules/paramloader"
+	"drift/modules/rng"
+	"drift/modules/stage"
 	"drift/types"
 	"fmt"
-	"math/rand"
+	"math/bits"
+	"slices"
 )
 
-// SeedThePopulation chooses a random seed individual and sets up their genetic data
+func init() {
+	stage.Register(stage.Seed, stage.Default, stage.StageFunc(
+		func(model *types.Model, pop *types.Pop, year int) error {
+			return SeedThePopulation(model, pop, year, int(model.Parameters["maturity"]))
+		}))
+}
 
-func SeedThePopulation(model *types.Model, pop *types.Pop, year int) {
-	seed := chooseRandomSeed(model, pop, year)
+// SeedThePopulation introduces the seed in seed_year, or later if there are
+// not yet enough eligible individuals. Maturity is the age at which the
+// lifecycle treats an individual as an adult, the youngest a seed can be
+// unless seed_min_age says otherwise.
+func SeedThePopulation(model *types.Model, pop *types.Pop, year int, maturity int) error {
+	if year < int(model.Parameters["seed_year"]) || model.FreeParameters["seed"] != -1 {
+		return nil
+	}
+	return introduce(model, pop, year, maturity)
+}
 
-	if seed == -1 {
-		return
+// introduce chooses the seed individuals according to seed_mode, or brings
+// them in as immigrants, and sets up their genetic data. Each seed is labelled
+// 1, 2, ... in the order chosen. If there are not yet enough eligible
+// individuals, it tries again the next year.
+func introduce(model *types.Model, pop *types.Pop, year int, maturity int) error {
+	var seeds []int
+	if model.Parameters["seed_immigrant"] == 1 {
+		seeds = addImmigrants(model, pop, year, maturity)
+	} else {
+		var err error
+		seeds, err = chooseSeeds(model, pop, year, maturity)
+		if err != nil {
+			return err
+		}
 	}
 
-	model.FreeParameters["seed"] = seed
-	fmt.Println("   Seed:", model.FreeParameters["seed"])
+	if len(seeds) == 0 {
+		return nil
+	}
 
-	// Create chromosomes
-	pop.Chromosomes[seed] = [][]uint64{
-		make([]uint64, (model.FreeParameters["NumBits"]+63)/64),
-		make([]uint64, (model.FreeParameters["NumBits"]+63)/64),
+	model.FreeParameters["seed"] = seeds[0]
+	pop.Seeds = seeds
+	if model.StringParameters["ancestry"] == "seeds" {
+		model.FreeParameters["num_labels"] = len(seeds)
+	}
+	for i, seed := range seeds {
+		fmt.Println("   Seed:", seed)
+		setUpSeed(model, pop, seed, i+1)
 	}
+	return nil
+}
+
+// setUpSeed gives a seed individual their label and a genome with every bit
+// they carry set to 1.
+func setUpSeed(model *types.Model, pop *types.Pop, seed int, label int) {
+	ind := pop.Ind(seed)
 
-	// Set all bits to 1 in chromosomes
-	for i := range pop.Chromosomes[seed][0] {
-		pop.Chromosomes[seed][0][i] = ^uint64(0)
+	// Create chromosomes, with all the bits the seed carries set to 1
+	carried := model.SexChromosomes.Carried[ind.Sex]
+	pop.Chromosomes[seed] = []genome.Haplotype{slices.Clone(carried[0]), slices.Clone(carried[1])}
+
+	// Create centromeres, likewise
+	cents := model.SexChromosomes.Cents[ind.Sex]
+	pop.Centromeres[seed] = []uint64{cents[0], cents[1]}
+
+	// Y descent is through males and mt descent through females, and both for
+	// hermaphrodites
+	hermaphrodite := model.StringParameters["mating_system"] == "hermaphrodite"
+	if ind.Sex == 0 || hermaphrodite {
+		ind.YGens = 0
 	}
-	for i := range pop.Chromosomes[seed][1] {
-		pop.Chromosomes[seed][1][i] = ^uint64(0)
+	if ind.Sex == 1 || hermaphrodite {
+		ind.MtGens = 0
 	}
-	// Create centromeres
-	pop.Centromeres[seed] = []uint64{0, 0}
-	// Set all bits to 1 in centromeres
-	for i := 1; i <= len(model.ChromosomeArms); i++ {
-		pop.Centromeres[seed][0] = setBit(pop.Centromeres[seed][0], i)
-		pop.Centromeres[seed][1] = setBit(pop.Centromeres[seed][1], i)
+	ind.MaxGenealoGens = 0
+	ind.MinGenealoGens = 0
+	ind.Seed = label
+	ind.SeedDescent = 1 << (label - 1)
+	ind.AlleleCount = carried[0].Count() + carried[1].Count()
+	ind.NumCentromeres = bits.OnesCount64(pop.Centromeres[seed][0]) + bits.OnesCount64(pop.Centromeres[seed][1])
+	if model.StringParameters["ancestry"] == "seeds" {
+		ancestry.Label(model, pop, seed, label)
 	}
-	pop.IndData[seed]["Y_gens"] = 0
-	pop.IndData[seed]["mt_gens"] = 0
-	pop.IndData[seed]["max_genealo_gens"] = 0
-	pop.IndData[seed]["min_genealo_gens"] = 0
-	pop.IndData[seed]["allele_count"] = model.FreeParameters["NumBits"] * 2
-	pop.IndData[seed]["num_centomeres"] = countSetBitsSingleVar(pop.Centromeres[seed][0])
-	pop.IndData[seed]["num_centomeres"] += countSetBitsSingleVar(pop.Centromeres[seed][1])
-
 }
 
-func chooseRandomSeed(model *types.Model, pop *types.Pop, year int) int {
+// chooseSeeds picks the seeds from the living population: num_seeds of them
+// at random for a cohort, a married couple, or the IDs listed in seed_ids. It
+// returns nil if there are not enough eligible individuals.
+func chooseSeeds(model *types.Model, pop *types.Pop, year int, maturity int) ([]int, error) {
+	rnd := model.RNG[rng.Seed]
+	switch model.StringParameters["seed_mode"] {
+	case "couple":
+		// the husband and wife must both pass the age filters
+		var husbands []int
+		for i := range pop.IndData {
+			ind := &pop.IndData[i]
+			if ind.Sex == 0 && ind.MarriageState != -1 && eligible(model, ind, year, maturity, false) &&
+				eligible(model, pop.Ind(ind.MarriageState), year, maturity, false) {
+				husbands = append(husbands, ind.ID)
+			}
+		}
+		if len(husbands) == 0 {
+			return nil, nil
+		}
+		husband := husbands[rnd.IntN(len(husbands))]
+		return []int{husband, pop.Ind(husband).MarriageState}, nil
+
+	case "ids":
+		ids, _ := paramloader.SeedIDs(model)
+		for _, id := range ids {
+			if pop.Ind(id) == nil {
+				return nil, fmt.Errorf("Error: seed %d is not in the population in year %d", id, year)
+			}
+		}
+		return ids, nil
+	}
+
+	// a cohort, drawn without replacement
+	var candidates []int
+	for i := range pop.IndData {
+		if eligible(model, &pop.IndData[i], year, maturity, true) {
+			candidates = append(candidates, pop.IndData[i].ID)
+		}
+	}
+	numSeeds := int(model.Parameters["num_seeds"])
+	if len(candidates) < numSeeds {
+		return nil, nil
+	}
+	for i := 0; i < numSeeds; i++ {
+		j := i + rnd.IntN(len(candidates)-i)
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	}
+	return candidates[:numSeeds], nil
+}
 
-	matureMales := []int{}
-	for id, data := range pop.IndData {
-		age := year - data["birth_year"]
-		if data["sex"] == 0 && age >= int(model.Parameters["maturity"]) {
-			matureMales = append(matureMales, id)
+// eligible applies the seed filters on age, marital state and, if checkSex is
+// set, sex. By default a seed must be at least the age of maturity.
+func eligible(model *types.Model, ind *types.Individual, year int, maturity int, checkSex bool) bool {
+	minAge, maxAge := ageRange(model, maturity)
+	age := year - ind.BirthYear
+	if age < minAge || (maxAge != -1 && age > maxAge) {
+		return false
+	}
+	switch model.StringParameters["seed_marital"] {
+	case "married":
+		if ind.MarriageState == -1 {
+			return false
+		}
+	case "unmarried":
+		if ind.MarriageState != -1 {
+			return false
 		}
 	}
-	if len(matureMales) == 0 {
-		return -1
+	// any sex will do for hermaphrodites
+	if !checkSex || model.StringParameters["mating_system"] == "hermaphrodite" {
+		return true
 	}
-	return matureMales[rand.Intn(len(matureMales))]
+	switch model.StringParameters["seed_sex"] {
+	case "male":
+		return ind.Sex == 0
+	case "female":
+		return ind.Sex == 1
+	}
+	return true
 }
 
-func setBit(value uint64, bitPosition int) uint64 {
-	return value | (1 << bitPosition)
+// ageRange returns the youngest and oldest a seed may be, with -1 for no
+// upper limit.
+func ageRange(model *types.Model, maturity int) (int, int) {
+	minAge := int(model.Parameters["seed_min_age"])
+	if minAge == -1 {
+		minAge = maturity
+	}
+	return minAge, int(model.Parameters["seed_max_age"])
 }
 
-func countSetBitsSingleVar(n uint64) int {
-	// Counts the number of bits set to 1 in n
-	count := 0
-	for n > 0 {
-		count += int(n & 1)
-		n >>= 1
+// addImmigrants brings the seeds in from outside the population: a married
+// couple, or a cohort of num_seeds. Their age is drawn evenly from the seed
+// age range, or is the youngest allowed if there is no upper limit.
+func addImmigrants(model *types.Model, pop *types.Pop, year int, maturity int) []int {
+	rnd := model.RNG[rng.Seed]
+	var sexes []int
+	if model.StringParameters["seed_mode"] == "couple" {
+		sexes = []int{0, 1}
+	} else {
+		for i := 0; i < int(model.Parameters["num_seeds"]); i++ {
+			switch model.StringParameters["seed_sex"] {
+			case "male":
+				sexes = append(sexes, 0)
+			case "female":
+				sexes = append(sexes, 1)
+			default:
+				sexes = append(sexes, rnd.IntN(2))
+			}
+		}
+	}
+
+	minAge, maxAge := ageRange(model, maturity)
+	var seeds []int
+	for _, sex := range sexes {
+		age := minAge
+		if maxAge > minAge {
+			age += rnd.IntN(maxAge - minAge + 1)
+		}
+		model.FreeParameters["indID"]++
+		id := model.FreeParameters["indID"]
+		pop.Add(types.Individual{
+			ID:             id,
+			Dad:            -1,
+			Mom:            -1,
+			Sex:            sex,
+			BirthYear:      year - age,
+			Lifespan:       int(model.Parameters["lifespan"]),
+			MarriageState:  -1,
+			Fitness:        int(model.Parameters["mu_scale_factor"]),
+			YGens:          -1,
+			MtGens:         -1,
+			MinGenealoGens: -1,
+			MaxGenealoGens: -1,
+			Lat:            rnd.IntN(1000) - 500,
+			Lon:            rnd.IntN(1000) - 500,
+		})
+		seeds = append(seeds, id)
+	}
+
+	// the immigrant couple arrive married
+	if len(seeds) == 2 && model.StringParameters["seed_mode"] == "couple" {
+		pop.Ind(seeds[0]).MarriageState = seeds[1]
+		pop.Ind(seeds[1]).MarriageState = seeds[0]
 	}
-	return count
+	return seeds
 }
@@ -0,0 +1,95 @@
+package selection
+
+import (
+	"cmp"
+	"drift/types"
+	"math/rand/v2"
+	"slices"
+)
+
+// Strategy says where selection acts on fitness, as chosen by the selection
+// parameter.
+type Strategy struct {
+	Birth     bool   // Couples have a child with a chance equal to their average fitness
+	Annual    bool   // The annual risk of death is divided by fitness
+	Viability bool   // Newborns survive with a chance equal to their fitness
+	Cull      string // How those culled to keep the population in check are chosen
+}
+
+// The ways of choosing who is culled.
+const (
+	Random      = ""
+	Truncation  = "truncation"  // the least fit first
+	Probability = "probability" // the lowest fitness times a random number first
+)
+
+var strategies = map[string]Strategy{
+	"off":          {},
+	"annual":       {Annual: true},
+	"birth":        {Birth: true},
+	"both":         {Birth: true, Annual: true},
+	"truncation":   {Cull: Truncation},
+	"probability":  {Cull: Probability},
+	"unrestricted": {Viability: true},
+}
+
+// For returns the selection strategy of a model. Without mutations there is
+// nothing to select on.
+func For(model *types.Model) Strategy {
+	if model.Parameters["track_mutations"] != 1 {
+		return Strategy{}
+	}
+	return strategies[model.StringParameters["selection"]]
+}
+
+// Fitness returns an individual's fitness, where 1 is no effect.
+func Fitness(model *types.Model, ind *types.Individual) float64 {
+	return float64(ind.Fitness) / model.Parameters["mu_scale_factor"]
+}
+
+// BirthChance returns the chance that a couple who try for a child have one.
+func (s Strategy) BirthChance(model *types.Model, dad *types.Individual, mom *types.Individual) float64 {
+	if !s.Birth {
+		return 1
+	}
+	return (Fitness(model, dad) + Fitness(model, mom)) / 2
+}
+
+// DeathRisk adjusts an individual's annual risk of death for their fitness,
+// dividing it by their fitness, so that halving fitness doubles the risk. The
+// risk is at most 1, which it is for anyone with no fitness left.
+func (s Strategy) DeathRisk(model *types.Model, ind *types.Individual, risk float64) float64 {
+	if !s.Annual {
+		return risk
+	}
+	fitness := Fitness(model, ind)
+	if fitness <= 0 {
+		return 1
+	}
+	return min(risk/fitness, 1)
+}
+
+// Rank orders individuals for culling, those to go first at the start. Ties
+// are broken at random.
+func (s Strategy) Rank(model *types.Model, pop *types.Pop, rnd *rand.Rand, ids []int) []int {
+	type entry struct {
+		id          int
+		score, ties float64
+	}
+	entries := make([]entry, len(ids))
+	for i, id := range ids {
+		score := Fitness(model, pop.Ind(id))
+		if s.Cull == Probability {
+			score *= rnd.Float64()
+		}
+		entries[i] = entry{id, score, rnd.Float64()}
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		return cmp.Or(cmp.Compare(a.score, b.score), cmp.Compare(a.ties, b.ties))
+	})
+	ranked := make([]int, len(entries))
+	for i, e := range entries {
+		ranked[i] = e.id
+	}
+	return ranked
+}
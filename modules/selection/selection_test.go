@@ -0,0 +1,34 @@
+package selection
+
+import (
+	"drift/types"
+	"testing"
+)
+
+func TestDeathRisk(t *testing.T) {
+	model := types.NewModel()
+	model.Parameters["mu_scale_factor"] = 1000
+	tests := []struct {
+		name     string
+		strategy Strategy
+		fitness  int // scaled by mu_scale_factor
+		risk     float64
+		want     float64
+	}{
+		{"no annual selection", Strategy{Birth: true}, 500, 0.1, 0.1},
+		{"full fitness", Strategy{Annual: true}, 1000, 0.1, 0.1},
+		{"half fitness doubles the risk", Strategy{Annual: true}, 500, 0.1, 0.2},
+		{"beneficial mutations lower the risk", Strategy{Annual: true}, 1250, 0.1, 0.08},
+		{"capped at 1", Strategy{Annual: true}, 100, 0.5, 1},
+		{"no fitness left", Strategy{Annual: true}, 0, 0.01, 1},
+		{"negative fitness", Strategy{Annual: true}, -200, 0.01, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ind := &types.Individual{Fitness: tt.fitness}
+			if got := tt.strategy.DeathRisk(model, ind, tt.risk); got != tt.want {
+				t.Fatalf("DeathRisk(%v) = %v, want %v", tt.risk, got, tt.want)
+			}
+		})
+	}
+}
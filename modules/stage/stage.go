@@ -0,0 +1,188 @@
+package stage
+
+import (
+	"drift/types"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Kinds of lifecycle stage that can be swapped out. The yearly stages run in
+// the order listed here.
+const (
+	InitializePop = "initializepop"
+	Seed          = "seed"
+	Birth         = "birth"
+	Marriage      = "marriage"
+	Death         = "death"
+	Save          = "save"
+)
+
+// Name under which each package registers its original implementation.
+const Default = "default"
+
+// Stage is one step of the yearly lifecycle. The current run number is
+// available in model.FreeParameters["run"].
+type Stage interface {
+	Step(model *types.Model, pop *types.Pop, year int) error
+}
+
+// StageFunc lets an ordinary function be used as a Stage.
+type StageFunc func(model *types.Model, pop *types.Pop, year int) error
+
+// Step calls f(model, pop, year).
+func (f StageFunc) Step(model *types.Model, pop *types.Pop, year int) error {
+	return f(model, pop, year)
+}
+
+// Initializer creates the starting population for a model run.
+type Initializer interface {
+	Init(model *types.Model, run int) (*types.Pop, error)
+}
+
+// InitializerFunc lets an ordinary function be used as an Initializer.
+type InitializerFunc func(model *types.Model, run int) (*types.Pop, error)
+
+// Init calls f(model, run).
+func (f InitializerFunc) Init(model *types.Model, run int) (*types.Pop, error) {
+	return f(model, run)
+}
+
+// Lifecycle is the set of modules chosen for a model.
+type Lifecycle struct {
+	InitializePop Initializer
+	Seed          Stage
+	Birth         Stage
+	Marriage      Stage
+	Death         Stage
+	Save          Stage
+}
+
+var stages = map[string]map[string]Stage{
+	Seed:     {},
+	Birth:    {},
+	Marriage: {},
+	Death:    {},
+	Save:     {},
+}
+
+var initializers = map[string]Initializer{}
+
+// Register makes a stage implementation available under the given name. It is
+// meant to be called from the init function of the package providing it.
+// Registering the same kind and name twice panics.
+func Register(kind string, name string, s Stage) {
+	registry, ok := stages[kind]
+	if !ok {
+		panic(fmt.Sprintf("stage: unknown stage kind %q", kind))
+	}
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("stage: %s module %q registered twice", kind, name))
+	}
+	registry[name] = s
+}
+
+// RegisterInitializer makes a population initializer available under the
+// given name.
+func RegisterInitializer(name string, i Initializer) {
+	if _, exists := initializers[name]; exists {
+		panic(fmt.Sprintf("stage: %s module %q registered twice", InitializePop, name))
+	}
+	initializers[name] = i
+}
+
+// Names returns the registered module names for a kind of stage, sorted.
+func Names(kind string) []string {
+	var names []string
+	if kind == InitializePop {
+		for name := range initializers {
+			names = append(names, name)
+		}
+	} else {
+		for name := range stages[kind] {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Load builds a Lifecycle from a scenario string. The scenario is a list of
+// kind=name assignments separated by semicolons, e.g.
+// "birth=discrete;marriage=assortative". Any stage that is not mentioned uses
+// the module registered under the engine's name, if there is one, and its
+// default module otherwise. An empty scenario, or "default", selects every
+// such module.
+func Load(scenario string, engine string) (*Lifecycle, error) {
+	choices := map[string]string{
+		InitializePop: Default,
+		Seed:          Default,
+		Birth:         Default,
+		Marriage:      Default,
+		Death:         Default,
+		Save:          Default,
+	}
+	if _, ok := initializers[engine]; ok {
+		choices[InitializePop] = engine
+	}
+	for kind := range stages {
+		if _, ok := stages[kind][engine]; ok {
+			choices[kind] = engine
+		}
+	}
+
+	scenario = strings.TrimSpace(scenario)
+	if scenario != "" && scenario != Default {
+		for _, assignment := range strings.Split(scenario, ";") {
+			assignment = strings.TrimSpace(assignment)
+			if assignment == "" {
+				continue
+			}
+			kind, name, found := strings.Cut(assignment, "=")
+			kind, name = strings.TrimSpace(kind), strings.TrimSpace(name)
+			if !found || name == "" {
+				return nil, fmt.Errorf("Error in scenario %q: %q is not of the form stage=module", scenario, assignment)
+			}
+			if _, ok := choices[kind]; !ok {
+				return nil, fmt.Errorf("Error in scenario %q: unknown stage %q", scenario, kind)
+			}
+			choices[kind] = name
+		}
+	}
+
+	lifecycle := &Lifecycle{}
+	var err error
+	if lifecycle.InitializePop, err = lookupInitializer(choices[InitializePop]); err != nil {
+		return nil, err
+	}
+	if lifecycle.Seed, err = lookup(Seed, choices[Seed]); err != nil {
+		return nil, err
+	}
+	if lifecycle.Birth, err = lookup(Birth, choices[Birth]); err != nil {
+		return nil, err
+	}
+	if lifecycle.Marriage, err = lookup(Marriage, choices[Marriage]); err != nil {
+		return nil, err
+	}
+	if lifecycle.Death, err = lookup(Death, choices[Death]); err != nil {
+		return nil, err
+	}
+	if lifecycle.Save, err = lookup(Save, choices[Save]); err != nil {
+		return nil, err
+	}
+	return lifecycle, nil
+}
+
+func lookup(kind string, name string) (Stage, error) {
+	if s, ok := stages[kind][name]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("Error: no %s module named %q (available: %s)", kind, name, strings.Join(Names(kind), ", "))
+}
+
+func lookupInitializer(name string) (Initializer, error) {
+	if i, ok := initializers[name]; ok {
+		return i, nil
+	}
+	return nil, fmt.Errorf("Error: no %s module named %q (available: %s)", InitializePop, name, strings.Join(Names(InitializePop), ", "))
+}
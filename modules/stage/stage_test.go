@@ -0,0 +1,66 @@
+package stage
+
+import (
+	"drift/types"
+	"testing"
+)
+
+// Named stages, so that the test can tell which module Load picked
+type named string
+
+func (n named) Step(model *types.Model, pop *types.Pop, year int) error {
+	return nil
+}
+
+func init() {
+	for _, kind := range []string{Seed, Birth, Marriage, Death, Save} {
+		Register(kind, Default, named(Default))
+	}
+	RegisterInitializer(Default, InitializerFunc(func(model *types.Model, run int) (*types.Pop, error) {
+		return nil, nil
+	}))
+	Register(Birth, "discrete", named("discrete"))
+	Register(Birth, "engine", named("engine"))
+	Register(Death, "engine", named("engine"))
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name      string
+		scenario  string
+		engine    string
+		wantBirth named
+		wantDeath named
+		wantErr   bool
+	}{
+		{"empty", "", "years", Default, Default, false},
+		{"default", "default", "years", Default, Default, false},
+		{"scenario module", "birth=discrete", "years", "discrete", Default, false},
+		{"spaces and empty assignments", " birth = discrete ;; ", "years", "discrete", Default, false},
+		{"engine modules", "", "engine", "engine", "engine", false},
+		{"scenario overrides the engine", "birth=discrete", "engine", "discrete", "engine", false},
+		{"scenario names the engine's module", "death=engine", "years", Default, "engine", false},
+		{"unknown module", "birth=nope", "years", "", "", true},
+		{"unknown stage", "mating=discrete", "years", "", "", true},
+		{"no module", "birth=", "years", "", "", true},
+		{"not an assignment", "birth", "years", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lifecycle, err := Load(tt.scenario, tt.engine)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load(%q, %q) error = %v, want error %v", tt.scenario, tt.engine, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if lifecycle.Birth != tt.wantBirth || lifecycle.Death != tt.wantDeath {
+				t.Fatalf("Load(%q, %q) chose birth %v and death %v, want %v and %v",
+					tt.scenario, tt.engine, lifecycle.Birth, lifecycle.Death, tt.wantBirth, tt.wantDeath)
+			}
+			if lifecycle.Marriage != named(Default) {
+				t.Fatalf("Load(%q, %q) chose marriage %v, want the default", tt.scenario, tt.engine, lifecycle.Marriage)
+			}
+		})
+	}
+}
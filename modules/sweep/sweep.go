@@ -0,0 +1,159 @@
+package sweep
+
+import (
+	"drift/modules/paramloader"
+	"drift/types"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// Spec describes a parameter sweep: the parameters that vary, every
+// combination of values to run, and how many replicates of each.
+type Spec struct {
+	Mode       string     // grid or list
+	Replicates int        // Runs per point, 0 to use num_runs
+	Names      []string   // The swept parameters
+	Points     [][]string // Values of the swept parameters for each point
+}
+
+// Load reads a sweep spec from a CSV file. The first row is "sweep,grid" or
+// "sweep,list", optionally followed by "replicates,N". In a grid, each further
+// row is a parameter name followed by its values and every combination is
+// run. In a list, the next row names the parameters and each row after that
+// is one point. Lines starting with # are ignored.
+func Load(path string) (*Spec, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading %s: %v", path, err)
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Error reading records from %s: %v", path, err)
+	}
+
+	invalid := func(record []string, message string) error {
+		return fmt.Errorf("Invalid record in %s: %v. %s", path, record, message)
+	}
+	if len(records) == 0 || len(records[0]) < 2 || records[0][0] != "sweep" {
+		return nil, fmt.Errorf("Error: %s should start with sweep,grid or sweep,list", path)
+	}
+	spec := &Spec{Mode: records[0][1]}
+	records = records[1:]
+	if len(records) > 0 && records[0][0] == "replicates" {
+		if len(records[0]) < 2 {
+			return nil, invalid(records[0], "No number of replicates")
+		}
+		spec.Replicates, err = strconv.Atoi(records[0][1])
+		if err != nil || spec.Replicates < 1 {
+			return nil, invalid(records[0], "Replicates should be a whole number of at least 1")
+		}
+		records = records[1:]
+	}
+
+	switch spec.Mode {
+	case "grid":
+		var values [][]string
+		for _, record := range records {
+			if len(record) < 2 {
+				return nil, invalid(record, "A grid row needs a parameter name and at least one value")
+			}
+			spec.Names = append(spec.Names, record[0])
+			values = append(values, record[1:])
+		}
+		spec.Points = combinations(values)
+	case "list":
+		if len(records) == 0 {
+			return nil, fmt.Errorf("Error: %s has no row of parameter names", path)
+		}
+		spec.Names = records[0]
+		for _, record := range records[1:] {
+			if len(record) != len(spec.Names) {
+				return nil, invalid(record, fmt.Sprintf("Expected %d values", len(spec.Names)))
+			}
+			spec.Points = append(spec.Points, record)
+		}
+	default:
+		return nil, invalid(append([]string{"sweep"}, spec.Mode), "The sweep should be a grid or a list")
+	}
+
+	for i, name := range spec.Names {
+		if name == "" || slices.Contains(spec.Names[:i], name) {
+			return nil, fmt.Errorf("Error: %s sweeps over %q more than once, or has a blank name", path, name)
+		}
+		// text parameters such as model_name and map_name name the output
+		// files, so every point would write over the same results
+		if !paramloader.Numeric(name) {
+			return nil, fmt.Errorf("Error: %s sweeps over %q, which is not a numeric model parameter", path, name)
+		}
+		for _, point := range spec.Points {
+			if _, err := paramloader.ParseNumeric(name, point[i]); err != nil {
+				return nil, invalid(point, err.Error())
+			}
+		}
+	}
+	if len(spec.Points) == 0 {
+		return nil, fmt.Errorf("Error: %s does not define any points", path)
+	}
+	return spec, nil
+}
+
+// combinations returns every way of picking one value from each list. The
+// last list changes fastest.
+func combinations(values [][]string) [][]string {
+	points := [][]string{{}}
+	for _, list := range values {
+		var next [][]string
+		for _, point := range points {
+			for _, value := range list {
+				next = append(next, append(slices.Clone(point), value))
+			}
+		}
+		points = next
+	}
+	return points
+}
+
+// Overrides returns the name=value settings for one point of the sweep,
+// including the number of replicates.
+func (spec *Spec) Overrides(point int) []string {
+	var overrides []string
+	for i, name := range spec.Names {
+		overrides = append(overrides, name+"="+spec.Points[point][i])
+	}
+	if spec.Replicates > 0 {
+		overrides = append(overrides, "num_runs="+strconv.Itoa(spec.Replicates))
+	}
+	return overrides
+}
+
+// SaveManifest writes a CSV file listing every point of the sweep with the
+// values of the swept parameters, the number of runs and the seed used.
+func SaveManifest(filename string, spec *Spec, models []*types.Model) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	headers := append([]string{"point"}, spec.Names...)
+	headers = append(headers, "runs", "rng_seed")
+	writer.Write(headers)
+	for i, model := range models {
+		row := append([]string{strconv.Itoa(model.Sweep.Index)}, spec.Points[i]...)
+		row = append(row,
+			strconv.Itoa(int(model.Parameters["num_runs"])),
+			strconv.FormatInt(int64(model.Parameters["rng_seed"]), 10),
+		)
+		writer.Write(row)
+	}
+	writer.Flush()
+	return writer.Error()
+}
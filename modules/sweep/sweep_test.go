@@ -0,0 +1,44 @@
+package sweep
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       string
+		wantPoints [][]string
+		wantErr    bool
+	}{
+		{"grid", "sweep,grid\nmu,1,2\nmax_pop_size,100,200\n",
+			[][]string{{"1", "100"}, {"1", "200"}, {"2", "100"}, {"2", "200"}}, false},
+		{"list", "sweep,list\nmu,rng_seed\n1,7\n2,8\n", [][]string{{"1", "7"}, {"2", "8"}}, false},
+		{"model name", "sweep,grid\nmodel_name,a,b\n", nil, true},
+		{"map name", "sweep,list\nmu,map_name\n1,a\n", nil, true},
+		{"file name", "sweep,grid\nchromosome_file,a.csv,b.csv\n", nil, true},
+		{"unknown parameter", "sweep,grid\nmutation_rate,1,2\n", nil, true},
+		{"plot flag", "sweep,grid\nnuminds,true,false\n", nil, true},
+		{"value out of range", "sweep,grid\nmax_pop_size,100,0\n", nil, true},
+		{"value not a number", "sweep,list\nmu\nhigh\n", nil, true},
+		{"repeated parameter", "sweep,grid\nmu,1\nmu,2\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sweep.csv")
+			if err := os.WriteFile(path, []byte(tt.spec), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			spec, err := Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !slices.EqualFunc(spec.Points, tt.wantPoints, slices.Equal) {
+				t.Errorf("Load points = %v, want %v", spec.Points, tt.wantPoints)
+			}
+		})
+	}
+}
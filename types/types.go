@@ -1,42 +1,202 @@
 package types
 
 import (
+	"drift/modules/genome"
 	"image/gif"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sort"
 	"sync"
 )
 
 type Model struct {
-	Parameters     map[string]float64
-	FreeParameters map[string]int
-	PlotFlags      map[string]bool
-	ChromosomeArms map[int]map[int][]int
-	DeathRisk      map[int]float64
-	CumulativeProb map[int]float64
-	Map            map[int]map[int]int
-	ModelName      string
-	MapName        string
+	Parameters       map[string]float64
+	FreeParameters   map[string]int
+	PlotFlags        map[string]bool
+	StringParameters map[string]string // Text and dropdown parameters, e.g. selection
+	ChromosomeArms   map[int]map[int][]int
+	SexChromosomes   SexChromosomes       // Sex chromosome system, read from the chromosome file
+	GeneticMap       [2]map[int][]float64 // By sex, the cumulative Morgans at each bit boundary of each chromosome, p arm first
+	FitnessCurve     [][2]float64         // Points (load, fitness) of the curve for the curve fitness model
+	DFETables        [2][][2]float64      // Deleterious and beneficial effects of an empirical DFE, with their cumulative probabilities
+	DFEMixtures      [2][]MixtureClass    // Deleterious and beneficial classes of a mixture DFE
+	DeathRisk        map[int]float64
+	CumulativeProb   map[int]float64
+	Map              map[int]map[int]int
+	RNG              map[string]*RandStream // Per-stage random number streams for the current run
+	Events           []Event                // Scheduled events, in order of year
+	Schedules        map[string]Schedule    // Parameters that change over time, by name
+	ModelName        string
+	MapName          string
+	Scenario         string      // Lifecycle modules to use, e.g. "birth=default;death=default"
+	Sweep            *SweepPoint // Set when the model is one point of a parameter sweep
+}
+
+// SexChromosomes describes the sex chromosome system. In the heterogametic
+// sex (males for XY and X0, females for ZW), the Y or W, or the missing
+// chromosome for X0, is on the strand from the parent of that sex.
+type SexChromosomes struct {
+	System  string                 // XY, ZW, X0 or none
+	X       int                    // The X or Z chromosome, 0 if none
+	Y       int                    // The Y or W chromosome, 0 if none
+	PAR     [][2]int               // Pseudo-autosomal bit ranges [start, end) on the X or Z
+	Carried [2][2]genome.Haplotype // Bits present on each strand, by sex
+	Cents   [2][2]uint64           // Centromeres present on each strand, by sex
+}
+
+// Heterogametic returns the sex with two different sex chromosomes, or -1 if
+// there are no sex chromosomes.
+func (s SexChromosomes) Heterogametic() int {
+	switch s.System {
+	case "XY", "X0":
+		return 0
+	case "ZW":
+		return 1
+	}
+	return -1
+}
+
+// Event is an action taken at the start of a model year.
+type Event struct {
+	Year   int
+	Action string  // bottleneck, mass_mortality, seed, or the name of a parameter to change
+	Value  float64 // New parameter value, bottleneck size or fraction that dies
+	Until  int     // Last year of a bottleneck
+}
+
+// Schedule gives the value of a parameter over time, as a list of points
+// joined either by straight lines or by steps.
+type Schedule struct {
+	Step   bool      // Hold each value until the next point rather than interpolating
+	Years  []int     // In increasing order
+	Values []float64 // Value at each year
+}
+
+// At returns the value of the schedule in the given year. Before the first
+// point and after the last, the value of the nearest point is used.
+func (s Schedule) At(year int) float64 {
+	i, _ := slices.BinarySearch(s.Years, year+1) // first point after year
+	switch {
+	case i == 0:
+		return s.Values[0]
+	case i == len(s.Years) || s.Step:
+		return s.Values[i-1]
+	}
+	fraction := float64(year-s.Years[i-1]) / float64(s.Years[i]-s.Years[i-1])
+	return s.Values[i-1] + fraction*(s.Values[i]-s.Values[i-1])
+}
+
+// SweepPoint identifies one combination of parameter values in a sweep.
+type SweepPoint struct {
+	Name   string   // Name of the sweep, all points share its results file
+	Index  int      // Position of the point in the sweep, from 1
+	Values []string // Values of the swept parameters, in the order they were given
 }
 
 type Pop struct {
-	IndData       map[int]map[string]int // Individual data
-	Chromosomes   map[int][][]uint64     // Genetic data
-	Centromeres   map[int][]uint64       // Centromere information
-	IndMutations  map[int]map[int][]int  // Mutations per individual
-	MutationPool  map[int]Mutation       // Global pool of mutations
-	MutationHist  map[int]int            // Mutation history/statistics
+	IndData       []Individual               // Individual data, living individuals only
+	IndIndex      map[int]int                // Position of each individual in IndData, by ID
+	Chromosomes   map[int][]genome.Haplotype // Genetic data, two haplotypes per individual
+	Centromeres   map[int][]uint64           // Centromere information
+	IndMutations  map[int]map[int][]int      // Mutations per individual
+	MutationPool  map[int]Mutation           // Global pool of mutations
+	MutationHist  map[int]int                // Mutation history/statistics
 	MutationCount int
 	Tracking      map[string]int
+	Seeds         []int              // IDs of the seed individuals, in the order of their labels
+	Ancestry      map[int][]Segments // Founder-labelled stretches of each strand
+	Descent       map[int][]uint16   // Founder labels each individual is descended from, in increasing order
 }
 
+// MixtureClass is one class of a mixture distribution of mutation effects.
+type MixtureClass struct {
+	Class    string
+	Fraction float64
+}
+
+// Segment is a stretch of a strand, the bits Start to End-1, that comes from
+// one founder label.
+type Segment struct {
+	Start int
+	End   int
+	Label uint16
+}
+
+// Segments lists the labelled stretches of a strand in order. Bits outside
+// them have no label, and touching segments have different labels.
+type Segments []Segment
+
+// At returns the label of a bit, 0 if it has none.
+func (s Segments) At(bit int) uint16 {
+	i := sort.Search(len(s), func(i int) bool { return s[i].End > bit })
+	if i < len(s) && s[i].Start <= bit {
+		return s[i].Label
+	}
+	return 0
+}
+
+type Individual struct {
+	ID             int    // Unique individual identifier
+	Dad            int    // ID of the father, -1 for founders
+	Mom            int    // ID of the mother, -1 for founders
+	Sex            int    // 0 = male, 1 = female
+	BirthYear      int    // Negative for people born before the model began
+	Lifespan       int    // Theoretical maximum lifespan
+	MarriageState  int    // ID of the spouse, -1 if unmarried
+	NumBirths      int    // Number of children, for females
+	LastBirthYear  int    // To allow for spacing between children
+	Fitness        int    // Fitness scaled by mu_scale_factor
+	AlleleCount    int    // Number of seed bits carried
+	NumBlocks      int    // Number of contiguous blocks of seed bits
+	NumCentromeres int    // Number of seed centromeres carried
+	NumMutations   int    // Number of mutations carried
+	YGens          int    // Generations from male seed, -1 if not descended
+	MtGens         int    // Generations from female seed, -1 if not descended
+	MinGenealoGens int    // Shortest path on family tree to seed, -1 if not descended
+	MaxGenealoGens int    // Longest path on family tree to seed, -1 if not descended
+	Seed           int    // Label of a seed individual, from 1, or 0 if not a seed
+	SeedDescent    uint64 // Seeds descended from, bit n-1 for the seed labelled n
+	Lat            int    // For non-random mating or geography
+	Lon            int
+}
+
+// MaxSeeds is the most seeds a run can have, since each one is a bit of
+// Individual.SeedDescent.
+const MaxSeeds = 64
+
 type Mutation struct {
 	Id        int     // Unique mutation identifier
 	Position  int     // Position in genome (base-pair level)
 	Effect    float64 // Mutation effect value
 	Origin    int     // Original individual
-	Dominance int     // 0, 100, or somewhere in between
+	Dominance float64 // Dominance coefficient h, from 0 (recessive) to 1 (dominant)
 	Count     int     // Number of instances in circulation
 }
 
+// RandStream is a random number generator together with its source, so the
+// source can be handed to gonum distributions and its state saved.
+type RandStream struct {
+	*rand.Rand
+	Source *rand.PCG
+}
+
+// GobEncode saves the state of the stream's source, so a checkpointed run
+// carries on with exactly the random numbers it would have drawn.
+func (s *RandStream) GobEncode() ([]byte, error) {
+	return s.Source.MarshalBinary()
+}
+
+// GobDecode restores a stream saved by GobEncode.
+func (s *RandStream) GobDecode(data []byte) error {
+	s.Source = &rand.PCG{}
+	if err := s.Source.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	s.Rand = rand.New(s.Source)
+	return nil
+}
+
 // type Chromosomes struct {
 // 	data [][]uint64
 // }
@@ -45,3 +205,106 @@ type AnimationManager struct {
 	animations map[string]*gif.GIF
 	mutex      sync.RWMutex
 }
+
+// NewModel returns a model with all of its maps created but nothing loaded.
+func NewModel() *Model {
+	return &Model{
+		Parameters:       make(map[string]float64),
+		PlotFlags:        make(map[string]bool),
+		StringParameters: make(map[string]string),
+		ChromosomeArms:   make(map[int]map[int][]int),
+		DeathRisk:        make(map[int]float64),
+		CumulativeProb:   make(map[int]float64),
+		FreeParameters:   make(map[string]int),
+		Map:              make(map[int]map[int]int),
+		RNG:              make(map[string]*RandStream),
+		Schedules:        make(map[string]Schedule),
+	}
+}
+
+// ParamAt returns the value of a parameter in the given year, taken from its
+// schedule if it has one and from Parameters otherwise.
+func (model *Model) ParamAt(name string, year int) float64 {
+	if schedule, ok := model.Schedules[name]; ok {
+		return schedule.At(year)
+	}
+	return model.Parameters[name]
+}
+
+// Clone returns a copy of the model for a single run. The parameter maps are
+// copied so the run can change them freely, while the tables loaded from the
+// config files, which runs only read, are shared.
+func (model *Model) Clone() *Model {
+	clone := *model
+	clone.Parameters = maps.Clone(model.Parameters)
+	clone.FreeParameters = maps.Clone(model.FreeParameters)
+	clone.PlotFlags = maps.Clone(model.PlotFlags)
+	clone.StringParameters = maps.Clone(model.StringParameters)
+	clone.RNG = maps.Clone(model.RNG)
+	return &clone
+}
+
+// NewPop returns an empty population.
+func NewPop() *Pop {
+	return &Pop{
+		IndIndex:     make(map[int]int),
+		Chromosomes:  make(map[int][]genome.Haplotype),
+		Centromeres:  make(map[int][]uint64),
+		IndMutations: make(map[int]map[int][]int),
+		MutationPool: make(map[int]Mutation),
+		MutationHist: make(map[int]int),
+		Tracking:     make(map[string]int),
+		Ancestry:     make(map[int][]Segments),
+		Descent:      make(map[int][]uint16),
+	}
+}
+
+// Ind returns the living individual with the given ID, or nil if there is
+// none. The pointer is only valid until the next call to Add or Remove.
+func (pop *Pop) Ind(id int) *Individual {
+	i, ok := pop.IndIndex[id]
+	if !ok {
+		return nil
+	}
+	return &pop.IndData[i]
+}
+
+// Alive reports whether the individual with the given ID is in the population.
+func (pop *Pop) Alive(id int) bool {
+	_, ok := pop.IndIndex[id]
+	return ok
+}
+
+// Add appends an individual to the population and returns a pointer to it.
+// The pointer is only valid until the next call to Add or Remove.
+func (pop *Pop) Add(ind Individual) *Individual {
+	pop.IndIndex[ind.ID] = len(pop.IndData)
+	pop.IndData = append(pop.IndData, ind)
+	return &pop.IndData[len(pop.IndData)-1]
+}
+
+// Remove deletes the individual with the given ID by moving the last
+// individual into its place. This changes the order of IndData, so loops that
+// remove individuals should range over a list of IDs made with IDs.
+func (pop *Pop) Remove(id int) {
+	i, ok := pop.IndIndex[id]
+	if !ok {
+		return
+	}
+	last := len(pop.IndData) - 1
+	if i != last {
+		pop.IndData[i] = pop.IndData[last]
+		pop.IndIndex[pop.IndData[i].ID] = i
+	}
+	pop.IndData = pop.IndData[:last]
+	delete(pop.IndIndex, id)
+}
+
+// IDs returns the IDs of all living individuals in the order they are stored.
+func (pop *Pop) IDs() []int {
+	ids := make([]int, len(pop.IndData))
+	for i := range pop.IndData {
+		ids[i] = pop.IndData[i].ID
+	}
+	return ids
+}
@@ -0,0 +1,85 @@
+package types
+
+import "testing"
+
+func TestScheduleAt(t *testing.T) {
+	linear := Schedule{Years: []int{100, 200, 400}, Values: []float64{1, 3, 2}}
+	step := Schedule{Step: true, Years: []int{100, 200, 400}, Values: []float64{1, 3, 2}}
+	single := Schedule{Years: []int{50}, Values: []float64{7}}
+	tests := []struct {
+		name     string
+		schedule Schedule
+		year     int
+		want     float64
+	}{
+		{"linear before the first point", linear, 0, 1},
+		{"linear at the first point", linear, 100, 1},
+		{"linear between points", linear, 150, 2},
+		{"linear at a middle point", linear, 200, 3},
+		{"linear falling", linear, 300, 2.5},
+		{"linear at the last point", linear, 400, 2},
+		{"linear after the last point", linear, 1000, 2},
+		{"step before the first point", step, 0, 1},
+		{"step between points", step, 199, 1},
+		{"step at a middle point", step, 200, 3},
+		{"step just before the last point", step, 399, 3},
+		{"step after the last point", step, 401, 2},
+		{"single point before", single, 0, 7},
+		{"single point after", single, 100, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.At(tt.year); got != tt.want {
+				t.Fatalf("At(%d) = %v, want %v", tt.year, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParamAt(t *testing.T) {
+	model := NewModel()
+	model.Parameters["mu"] = 1
+	model.Parameters["birth_prob"] = 4
+	model.Schedules["mu"] = Schedule{Years: []int{0, 10}, Values: []float64{0, 10}}
+	tests := []struct {
+		name string
+		year int
+		want float64
+	}{
+		{"mu", 5, 5},
+		{"birth_prob", 5, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := model.ParamAt(tt.name, tt.year); got != tt.want {
+				t.Fatalf("ParamAt(%q, %d) = %v, want %v", tt.name, tt.year, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegmentsAt(t *testing.T) {
+	segments := Segments{{Start: 2, End: 5, Label: 1}, {Start: 5, End: 6, Label: 2}, {Start: 9, End: 12, Label: 3}}
+	tests := []struct {
+		bit  int
+		want uint16
+	}{
+		{0, 0},
+		{2, 1},
+		{4, 1},
+		{5, 2},
+		{6, 0},
+		{8, 0},
+		{9, 3},
+		{11, 3},
+		{12, 0},
+	}
+	for _, tt := range tests {
+		if got := segments.At(tt.bit); got != tt.want {
+			t.Errorf("At(%d) = %d, want %d", tt.bit, got, tt.want)
+		}
+	}
+	if got := Segments(nil).At(3); got != 0 {
+		t.Errorf("At(3) on no segments = %d, want 0", got)
+	}
+}